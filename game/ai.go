@@ -0,0 +1,164 @@
+package game
+
+import (
+	"fmt"
+	"math"
+)
+
+// AIState - what a monster is currently doing
+type AIState int
+
+const (
+	Wandering AIState = iota
+	Asleep
+	Hunting
+	Searching
+	Fleeing
+)
+
+var aiStateNames = [...]string{"wandering", "asleep", "hunting", "searching", "fleeing"}
+
+func (state AIState) String() string {
+	if state < 0 || int(state) >= len(aiStateNames) {
+		return "unknown"
+	}
+	return aiStateNames[state]
+}
+
+// behaviour - an AI profile from the bestiary
+type behaviour struct {
+	start AIState // state monsters are spawned in
+	flees bool    // runs away at low hitpoints
+}
+
+var behaviours = map[string]behaviour{
+	"hunter":   {Wandering, false},
+	"sleeper":  {Asleep, false},
+	"skittish": {Wandering, true},
+	"":         {Wandering, false},
+}
+
+const (
+	searchTurns   = 10   // turns spent looking around the last known position
+	wanderRadius  = 8    // how far a wandering monster picks its next destination
+	fleeThreshold = 0.25 // fraction of max hitpoints at which skittish monsters run
+)
+
+// setState - changes state and marks the change on the debug overlay
+func (m *Monster) setState(level *Level, state AIState) {
+	if m.State == state {
+		return
+	}
+	level.Debug[m.Pos] = fmt.Sprintf("%s: %s -> %s", m.Name, m.State, state)
+	m.State = state
+}
+
+// think - moves the monster between states based on what it can see
+func (m *Monster) think(level *Level) {
+	player := level.Player
+	sees := m.State != Asleep && m.seesPlayer(level)
+	hurt := m.Hitpoints < m.MaxHitpoints
+	if sees {
+		m.LastSeen = player.Pos
+	}
+
+	if m.State != Asleep && behaviours[m.Behaviour].flees && float64(m.Hitpoints) < float64(m.MaxHitpoints)*fleeThreshold {
+		m.setState(level, Fleeing)
+		return
+	}
+
+	switch m.State {
+	case Asleep:
+		// sleeping monsters only wake up to noise, or when they are hurt
+		if hurt {
+			m.LastSeen = player.Pos
+			m.setState(level, Hunting)
+		}
+	case Wandering:
+		if sees {
+			m.setState(level, Hunting)
+		}
+	case Hunting:
+		if !sees {
+			m.SearchTurns = searchTurns
+			m.setState(level, Searching)
+		}
+	case Searching:
+		if sees {
+			m.setState(level, Hunting)
+		} else if m.SearchTurns <= 0 {
+			m.setState(level, Wandering)
+		}
+	case Fleeing:
+		if float64(m.Hitpoints) >= float64(m.MaxHitpoints)*fleeThreshold*2 {
+			m.setState(level, Wandering)
+		}
+	}
+}
+
+// act - takes the action for the current state
+func (m *Monster) act(level *Level) {
+	switch m.State {
+	case Hunting:
+		if next, ok := level.PlayerMap().Downhill(level, m.Pos); ok {
+			m.Move(next, level)
+		}
+	case Searching:
+		if m.Pos == m.LastSeen {
+			m.SearchTurns--
+			m.wander(level)
+		} else if !m.stepTowards(level, m.LastSeen) {
+			m.SearchTurns = 0
+		}
+	case Wandering:
+		m.wander(level)
+	case Fleeing:
+		m.flee(level)
+	}
+}
+
+// stepTowards - one step along the shortest path to goal, false if there is no path
+func (m *Monster) stepTowards(level *Level, goal Pos) bool {
+	path := level.astar(m.Pos, goal)
+	if len(path) < 2 {
+		return false
+	}
+	m.Move(path[1], level)
+	return true
+}
+
+// wander - heads for a random nearby spot, picking a new one on arrival
+func (m *Monster) wander(level *Level) {
+	if m.Target == m.Pos || !canWalk(level, m.Target) {
+		m.Target = m.Pos
+		for tries := 0; tries < 10; tries++ {
+			target := Pos{
+				m.X + level.AI.Intn(2*wanderRadius+1) - wanderRadius,
+				m.Y + level.AI.Intn(2*wanderRadius+1) - wanderRadius,
+			}
+			if canWalk(level, target) {
+				m.Target = target
+				break
+			}
+		}
+	}
+	if m.Target != m.Pos && !m.stepTowards(level, m.Target) {
+		m.Target = m.Pos
+	}
+}
+
+// flee - runs down the flee map, fights back when cornered
+func (m *Monster) flee(level *Level) {
+	if next, ok := level.FleeMap().Downhill(level, m.Pos); ok && next != level.Player.Pos {
+		m.Move(next, level)
+	} else if m.Pos.IsNextToPlayer(level) {
+		m.Move(level.Player.Pos, level)
+	}
+}
+
+// distance - straight line distance between two positions
+func (pos Pos) distance(other Pos) float64 {
+	dx := float64(pos.X - other.X)
+	dy := float64(pos.Y - other.Y)
+	return math.Sqrt(dx*dx + dy*dy)
+}
@@ -0,0 +1,206 @@
+package game
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+// bestiaryJSON - the monsters shipped with the game, see data/bestiary.json
+//
+//go:embed data/bestiary.json
+var bestiaryJSON []byte
+
+// bestiary - the registry consulted by the level loader and worldgen
+var bestiary = mustLoadBestiary(bestiaryJSON)
+
+type LootEntry struct {
+	Item   string  `json:"item"`   // item kind
+	Chance float64 `json:"chance"` // 0 to 1
+}
+
+// MonsterDef - everything needed to spawn a kind of monster
+// behaviour is one of the AI profiles: "hunter" wanders until it sees the player,
+// "sleeper" starts asleep and "skittish" runs away when badly hurt
+// maxDepth of 0 means the monster spawns at any depth below minDepth
+type MonsterDef struct {
+	Kind       string      `json:"kind"`
+	Name       string      `json:"name"`
+	Glyph      string      `json:"glyph"`
+	Hitpoints  int         `json:"hitpoints"`
+	Strength   int         `json:"strength"`
+	Accuracy   int         `json:"accuracy"`
+	Evasion    int         `json:"evasion"`
+	Damage     string      `json:"damage"`
+	Speed      float64     `json:"speed"`
+	Breath     int         `json:"breath"`
+	SightRange int         `json:"sightRange"`
+	Behaviour  string      `json:"behaviour"`
+	XP         int         `json:"xp"`
+	MinDepth   int         `json:"minDepth"`
+	MaxDepth   int         `json:"maxDepth"`
+	Loot       []LootEntry `json:"loot"`
+	OnHit      *EffectSpec `json:"onHit"`
+	Light      int         `json:"light"` // glowing monsters light up the tiles around them
+	symbol     rune
+	onHit      *Effect
+	damage     Dice
+}
+
+type Bestiary struct {
+	defs   []*MonsterDef // in file order so spawning is deterministic
+	kinds  map[string]*MonsterDef
+	glyphs map[rune]*MonsterDef
+}
+
+// LoadBestiary - parses and validates a bestiary
+func LoadBestiary(r io.Reader) (*Bestiary, error) {
+	file := struct {
+		Monsters []*MonsterDef `json:"monsters"`
+	}{}
+	if err := json.NewDecoder(r).Decode(&file); err != nil {
+		return nil, fmt.Errorf("bestiary: %v", err)
+	}
+
+	b := &Bestiary{}
+	b.kinds = make(map[string]*MonsterDef)
+	b.glyphs = make(map[rune]*MonsterDef)
+	reserved := baseLegend()
+	for i, def := range file.Monsters {
+		if def.Kind == "" || def.Name == "" {
+			return nil, fmt.Errorf("bestiary: monster %d needs a kind and a name", i)
+		}
+		glyph := []rune(def.Glyph)
+		if len(glyph) != 1 {
+			return nil, fmt.Errorf("bestiary: %s glyph must be a single character", def.Kind)
+		}
+		def.symbol = glyph[0]
+		if _, exists := reserved[def.symbol]; exists {
+			return nil, fmt.Errorf("bestiary: %s glyph %q is already a tile", def.Kind, def.symbol)
+		}
+		if _, exists := b.kinds[def.Kind]; exists {
+			return nil, fmt.Errorf("bestiary: duplicate kind %s", def.Kind)
+		}
+		if other, exists := b.glyphs[def.symbol]; exists {
+			return nil, fmt.Errorf("bestiary: %s and %s share glyph %q", other.Kind, def.Kind, def.symbol)
+		}
+		if def.Hitpoints <= 0 || def.Speed <= 0 {
+			return nil, fmt.Errorf("bestiary: %s needs positive hitpoints and speed", def.Kind)
+		}
+		if def.Damage != "" {
+			dice, err := ParseDice(def.Damage)
+			if err != nil {
+				return nil, fmt.Errorf("bestiary: %s: %v", def.Kind, err)
+			}
+			def.damage = dice
+		}
+		if _, exists := behaviours[def.Behaviour]; !exists {
+			return nil, fmt.Errorf("bestiary: %s has unknown behaviour %q", def.Kind, def.Behaviour)
+		}
+		if def.OnHit != nil {
+			effect, err := def.OnHit.parse()
+			if err != nil {
+				return nil, fmt.Errorf("bestiary: %s: %v", def.Kind, err)
+			}
+			def.onHit = &effect
+		}
+		for _, loot := range def.Loot {
+			if _, exists := itemCatalog.Def(loot.Item); !exists {
+				return nil, fmt.Errorf("bestiary: %s drops unknown item %q", def.Kind, loot.Item)
+			}
+		}
+		b.defs = append(b.defs, def)
+		b.kinds[def.Kind] = def
+		b.glyphs[def.symbol] = def
+	}
+	return b, nil
+}
+
+// LoadBestiaryFile - reads the monster definitions in filename
+func LoadBestiaryFile(filename string) (*Bestiary, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return LoadBestiary(file)
+}
+
+// SetBestiary - replaces the monsters used by every level loaded or generated afterwards
+func SetBestiary(b *Bestiary) {
+	bestiary = b
+}
+
+func mustLoadBestiary(data []byte) *Bestiary {
+	b, err := LoadBestiary(bytes.NewReader(data))
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// Def - looks up a monster by kind
+func (b *Bestiary) Def(kind string) (*MonsterDef, bool) {
+	def, exists := b.kinds[kind]
+	return def, exists
+}
+
+// SpawnsAt - monsters allowed to spawn at depth
+func (b *Bestiary) SpawnsAt(depth int) []*MonsterDef {
+	defs := make([]*MonsterDef, 0)
+	for _, def := range b.defs {
+		if depth >= def.MinDepth && (def.MaxDepth == 0 || depth <= def.MaxDepth) {
+			defs = append(defs, def)
+		}
+	}
+	return defs
+}
+
+// Symbol - the glyph used for this monster in maps and the texture atlas
+func (def *MonsterDef) Symbol() rune {
+	return def.symbol
+}
+
+func (def *MonsterDef) NewMonster(p Pos) *Monster {
+	monster := &Monster{}
+	monster.Pos = p
+	monster.Kind = def.Kind
+	monster.Behaviour = def.Behaviour
+	monster.State = behaviours[def.Behaviour].start
+	monster.Target = p
+	monster.Symbol = def.symbol
+	monster.Name = def.Name
+	monster.Hitpoints = def.Hitpoints
+	monster.MaxHitpoints = def.Hitpoints
+	monster.Strength = def.Strength
+	monster.Accuracy = def.Accuracy
+	monster.Evasion = def.Evasion
+	monster.Damage = def.damage
+	if def.onHit != nil {
+		effect := *def.onHit
+		monster.OnHit = &effect
+		monster.OnHitChance = def.OnHit.Chance
+	}
+	monster.Speed = def.Speed
+	monster.NextAction = 0
+	monster.MaxBreath = def.Breath
+	monster.CurrentBreath = monster.MaxBreath
+	monster.SightRange = def.SightRange
+	monster.Light = def.Light
+	return monster
+}
+
+// rollLoot - items dropped by a monster of this kind when it dies
+func (def *MonsterDef) rollLoot(p Pos, r *RNG) []*Item {
+	items := make([]*Item, 0)
+	for _, loot := range def.Loot {
+		def, exists := itemCatalog.Def(loot.Item)
+		if exists && r.Float64() < loot.Chance {
+			items = append(items, def.NewItem(p))
+		}
+	}
+	return items
+}
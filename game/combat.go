@@ -0,0 +1,130 @@
+package game
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Dice - Count rolls of a Sides sided die plus Bonus, written like 2d6+1
+type Dice struct {
+	Count int
+	Sides int
+	Bonus int
+}
+
+// ParseDice - reads dice written as NdS, NdS+B or NdS-B
+func ParseDice(s string) (Dice, error) {
+	dice := Dice{}
+	rest := strings.TrimSpace(s)
+	d := strings.Index(rest, "d")
+	if d <= 0 {
+		return dice, fmt.Errorf("dice %q: expected NdS", s)
+	}
+	var err error
+	dice.Count, err = strconv.Atoi(rest[:d])
+	if err != nil {
+		return dice, fmt.Errorf("dice %q: %v", s, err)
+	}
+	rest = rest[d+1:]
+	if i := strings.IndexAny(rest, "+-"); i >= 0 {
+		dice.Bonus, err = strconv.Atoi(rest[i:])
+		if err != nil {
+			return dice, fmt.Errorf("dice %q: %v", s, err)
+		}
+		rest = rest[:i]
+	}
+	dice.Sides, err = strconv.Atoi(rest)
+	if err != nil {
+		return dice, fmt.Errorf("dice %q: %v", s, err)
+	}
+	if dice.Count < 1 || dice.Sides < 1 {
+		return dice, fmt.Errorf("dice %q: need at least one die with one side", s)
+	}
+	return dice, nil
+}
+
+func (dice Dice) String() string {
+	if dice.Bonus > 0 {
+		return fmt.Sprintf("%dd%d+%d", dice.Count, dice.Sides, dice.Bonus)
+	} else if dice.Bonus < 0 {
+		return fmt.Sprintf("%dd%d%d", dice.Count, dice.Sides, dice.Bonus)
+	}
+	return fmt.Sprintf("%dd%d", dice.Count, dice.Sides)
+}
+
+func (dice Dice) Roll(r *RNG) int {
+	total := dice.Bonus
+	for i := 0; i < dice.Count; i++ {
+		total += r.Intn(dice.Sides) + 1
+	}
+	return total
+}
+
+// DamageDice - the wielded weapon's dice, or the character's own if unarmed
+func (c *Character) DamageDice() Dice {
+	weapon := c.Equipment[SlotWeapon]
+	if weapon != nil && weapon.Damage.Count > 0 {
+		return weapon.Damage
+	}
+	if c.Damage.Count == 0 {
+		return Dice{1, 1, 0}
+	}
+	return c.Damage
+}
+
+type AttackResult struct {
+	Roll     int // the d20, 1 always misses and 20 always crits
+	Hit      bool
+	Critical bool
+	Damage   int // after armour
+	Absorbed int // soaked up by armour
+}
+
+// Combat rules
+const (
+	hitTarget      = 10 // d20 + accuracy must reach this + evasion
+	criticalRoll   = 20
+	fumbleRoll     = 1
+	criticalFactor = 2
+)
+
+// resolveAttack - rolls to hit, then damage, then takes off armour
+// doesn't change either character
+func resolveAttack(attacker, defender *Character, r *RNG) AttackResult {
+	result := AttackResult{}
+	result.Roll = r.Intn(20) + 1
+	switch {
+	case result.Roll == fumbleRoll:
+		return result
+	case result.Roll == criticalRoll:
+		result.Critical = true
+	case result.Roll+attacker.Accuracy < hitTarget+defender.Evasion:
+		return result
+	}
+	result.Hit = true
+
+	damage := attacker.DamageDice().Roll(r) + attacker.AttackPower()
+	if result.Critical {
+		damage *= criticalFactor
+	}
+	result.Absorbed = defender.Defense()
+	if result.Absorbed > damage {
+		result.Absorbed = damage
+	}
+	result.Damage = damage - result.Absorbed
+	return result
+}
+
+// describeAttack - the event log line for an attack
+func describeAttack(attacker, defender *Character, result AttackResult) string {
+	switch {
+	case !result.Hit:
+		return fmt.Sprintf("%s misses %s", attacker.Name, defender.Name)
+	case result.Critical:
+		return fmt.Sprintf("%s lands a critical hit on %s for %d", attacker.Name, defender.Name, result.Damage)
+	case result.Damage == 0:
+		return fmt.Sprintf("%s hits %s but the armour holds", attacker.Name, defender.Name)
+	}
+	return fmt.Sprintf("%s hits %s for %d", attacker.Name, defender.Name, result.Damage)
+}
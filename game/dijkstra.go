@@ -0,0 +1,167 @@
+package game
+
+import "math"
+
+// Unreachable - distance of positions no goal can be reached from
+const Unreachable = math.MaxInt32
+
+// fleeFactor - how strongly a flee map prefers getting away over running into corners, in tenths
+const fleeFactor = -12
+
+// DijkstraMap - distance from every position on the level to the nearest goal
+// monsters roll downhill on it, so a single map serves every monster after the same goal
+type DijkstraMap struct {
+	width, height int
+	dist          []int
+}
+
+// NewDijkstraMap - distances to the nearest of goals, walls and closed doors block but monsters don't
+func (level *Level) NewDijkstraMap(goals []Pos) *DijkstraMap {
+	d := level.emptyDijkstraMap()
+	seeds := make([]Pos, 0, len(goals))
+	for _, goal := range goals {
+		if inRange(level, goal) {
+			d.dist[d.index(goal)] = 0
+			seeds = append(seeds, goal)
+		}
+	}
+	d.relax(level, seeds)
+	return d
+}
+
+// FleeMap - a map for running away from the goals of d
+// distances are inverted and rescanned so monsters head for open space rather than the nearest dead end
+func (d *DijkstraMap) FleeMap(level *Level) *DijkstraMap {
+	flee := level.emptyDijkstraMap()
+	seeds := make([]Pos, 0)
+	for i, dist := range d.dist {
+		if dist == Unreachable {
+			continue
+		}
+		flee.dist[i] = dist * fleeFactor / costScale
+		seeds = append(seeds, Pos{i % d.width, i / d.width})
+	}
+	flee.relax(level, seeds)
+	return flee
+}
+
+// At - distance from pos to the nearest goal
+func (d *DijkstraMap) At(pos Pos) int {
+	if pos.X < 0 || pos.Y < 0 || pos.X >= d.width || pos.Y >= d.height {
+		return Unreachable
+	}
+	return d.dist[d.index(pos)]
+}
+
+// Downhill - the free neighbour of from that gets closest to a goal, false if none is closer
+// the player's position counts as free so hunters end up attacking
+func (d *DijkstraMap) Downhill(level *Level, from Pos) (Pos, bool) {
+	best := from
+	for _, next := range level.steps(from) {
+		if next != level.Player.Pos && !canWalk(level, next) {
+			continue
+		}
+		if d.At(next) < d.At(best) {
+			best = next
+		}
+	}
+	return best, best != from
+}
+
+func (level *Level) emptyDijkstraMap() *DijkstraMap {
+	d := &DijkstraMap{width: len(level.Map[0]), height: len(level.Map)}
+	d.dist = make([]int, d.width*d.height)
+	for i := range d.dist {
+		d.dist[i] = Unreachable
+	}
+	return d
+}
+
+func (d *DijkstraMap) index(pos Pos) int {
+	return pos.Y*d.width + pos.X
+}
+
+// relax - spreads distances out from seeds until nothing gets any shorter
+func (d *DijkstraMap) relax(level *Level, seeds []Pos) {
+	frontier := make(pqueue, 0, len(seeds))
+	for _, seed := range seeds {
+		frontier = frontier.push(seed, d.At(seed))
+	}
+	var current Pos
+	for len(frontier) > 0 {
+		frontier, current = frontier.pop()
+		for _, next := range level.steps(current) {
+			dist := d.At(current) + tileCost(level, next)
+			if dist >= d.At(next) {
+				continue
+			}
+			d.dist[d.index(next)] = dist
+			frontier = frontier.push(next, dist)
+		}
+	}
+}
+
+// flowFields - shared maps for the current player position, rebuilt at most once per player action
+type flowFields struct {
+	origin Pos
+	player *DijkstraMap
+	flee   *DijkstraMap
+	items  *DijkstraMap
+	exits  *DijkstraMap
+}
+
+// fields - the flow fields for where the player stands now
+func (level *Level) fields() *flowFields {
+	if level.flow == nil || level.flow.origin != level.Player.Pos {
+		level.flow = &flowFields{origin: level.Player.Pos}
+	}
+	return level.flow
+}
+
+// PlayerMap - distance to the player, used by everything hunting them
+func (level *Level) PlayerMap() *DijkstraMap {
+	f := level.fields()
+	if f.player == nil {
+		f.player = level.NewDijkstraMap([]Pos{level.Player.Pos})
+	}
+	return f.player
+}
+
+// FleeMap - for getting away from the player
+func (level *Level) FleeMap() *DijkstraMap {
+	f := level.fields()
+	if f.flee == nil {
+		f.flee = level.PlayerMap().FleeMap(level)
+	}
+	return f.flee
+}
+
+// ItemMap - desire map towards items lying on the floor
+func (level *Level) ItemMap() *DijkstraMap {
+	f := level.fields()
+	if f.items == nil {
+		goals := make([]Pos, 0, len(level.Items))
+		for pos := range level.Items {
+			goals = append(goals, pos)
+		}
+		f.items = level.NewDijkstraMap(goals)
+	}
+	return f.items
+}
+
+// ExitMap - desire map towards the stairs
+func (level *Level) ExitMap() *DijkstraMap {
+	f := level.fields()
+	if f.exits == nil {
+		goals := make([]Pos, 0)
+		for y, row := range level.Map {
+			for x, tile := range row {
+				if tile.Symbol == UpStairs || tile.Symbol == DownStairs {
+					goals = append(goals, Pos{x, y})
+				}
+			}
+		}
+		f.exits = level.NewDijkstraMap(goals)
+	}
+	return f.exits
+}
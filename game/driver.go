@@ -0,0 +1,77 @@
+package game
+
+// Driver - plays a game without a ui, one input at a time
+// nothing is sent over channels, so tests and simulations can look at the level between inputs
+type Driver struct {
+	Game *Game
+}
+
+// NewDriver - starts a headless game, see NewGame
+func NewDriver(levelPath string, seed int64) (*Driver, error) {
+	game, err := NewGame(levelPath, seed)
+	if err != nil {
+		return nil, err
+	}
+	return &Driver{game}, nil
+}
+
+// NewLevelDriver - plays a single level, for tests that draw their own small maps
+// there are no other depths and no victory, restarting generates a fresh world
+func NewLevelDriver(level *Level) *Driver {
+	if level.Connectivity == 0 {
+		level.Connectivity = FourWay
+	}
+	world := &World{}
+	world.Levels = map[int]*Level{0: level}
+	world.LevelPaths = make(map[int]string)
+	world.Connectivity = level.Connectivity
+	return &Driver{newGame(world)}
+}
+
+// Step - handles a single input and returns the level the player ends up on
+// window inputs are ignored, there are no windows to close
+func (d *Driver) Step(input Input) *Level {
+	game := d.Game
+	if input.Typ != CloseWindow && input.Typ != QuitGame {
+		game.record(&input)
+		game.handleInput(&input)
+	}
+	return game.Level
+}
+
+// Press - steps through a sequence of plain inputs
+func (d *Driver) Press(typs ...InputType) *Level {
+	for _, typ := range typs {
+		d.Step(Input{Typ: typ})
+	}
+	return d.Game.Level
+}
+
+// Play - keeps asking choose for the next input until the game is no longer being played
+// or turns inputs have been handled, returns how many were
+func (d *Driver) Play(turns int, choose func(level *Level) Input) int {
+	for i := 0; i < turns; i++ {
+		if d.Game.State != Playing {
+			return i
+		}
+		d.Step(choose(d.Game.Level))
+	}
+	return turns
+}
+
+func (d *Driver) Level() *Level {
+	return d.Game.Level
+}
+
+func (d *Driver) Player() *Player {
+	return d.Game.Level.Player
+}
+
+func (d *Driver) State() GameState {
+	return d.Game.State
+}
+
+// View - what a ui would be sent after the last input
+func (d *Driver) View() *View {
+	return d.Game.Level.Snapshot(d.Game.State)
+}
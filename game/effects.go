@@ -0,0 +1,218 @@
+package game
+
+import (
+	"fmt"
+	"strings"
+)
+
+type EffectKind int
+
+const (
+	Poison EffectKind = iota
+	Bleeding
+	Slow
+	Haste
+	Regeneration
+	Drowning
+)
+
+// Permanent - effect lasts until something removes it
+const Permanent = -1
+
+type Effect struct {
+	Kind    EffectKind
+	Turns   int // turns left, or Permanent
+	Potency int
+}
+
+// StackRule - what happens when an effect is applied to someone who already has it
+type StackRule int
+
+const (
+	StackRefresh   StackRule = iota // keep the longer duration and stronger potency
+	StackIntensity                  // potencies add up
+	StackDuration                   // durations add up
+)
+
+type effectRule struct {
+	Name     string
+	Stacking StackRule
+	Damage   int     // hitpoints lost each turn per potency
+	Heal     int     // hitpoints gained each turn per potency
+	Speed    float64 // speed multiplier while active
+	Death    string  // cause of death when this effect kills the player
+}
+
+var effectRules = map[EffectKind]effectRule{
+	Poison:       {"poisoned", StackIntensity, 2, 0, 1.0, "died of poison"},
+	Bleeding:     {"bleeding", StackDuration, 3, 0, 1.0, "bled to death"},
+	Slow:         {"slowed", StackRefresh, 0, 0, 0.5, ""},
+	Haste:        {"hasted", StackRefresh, 0, 0, 1.5, ""},
+	Regeneration: {"regenerating", StackRefresh, 0, 5, 1.0, ""},
+	Drowning:     {"drowning", StackRefresh, 0, 0, 1.0, "drowned"},
+}
+
+// effectNames - names used for effects in data files
+var effectNames = map[string]EffectKind{
+	"poison":       Poison,
+	"bleeding":     Bleeding,
+	"slow":         Slow,
+	"haste":        Haste,
+	"regeneration": Regeneration,
+}
+
+func (kind EffectKind) String() string {
+	return effectRules[kind].Name
+}
+
+// EffectSpec - an effect as written in the bestiary and item catalog
+type EffectSpec struct {
+	Effect  string  `json:"effect"`
+	Turns   int     `json:"turns"`
+	Potency int     `json:"potency"`
+	Chance  float64 `json:"chance"` // for effects applied on hit, 0 means every hit
+}
+
+func (spec *EffectSpec) parse() (Effect, error) {
+	kind, exists := effectNames[spec.Effect]
+	if !exists {
+		return Effect{}, fmt.Errorf("unknown effect %q", spec.Effect)
+	}
+	if spec.Turns <= 0 {
+		return Effect{}, fmt.Errorf("effect %s needs a positive number of turns", spec.Effect)
+	}
+	potency := spec.Potency
+	if potency == 0 {
+		potency = 1
+	}
+	return Effect{kind, spec.Turns, potency}, nil
+}
+
+// AddEffect - applies e, stacking with any effect of the same kind
+func (c *Character) AddEffect(e Effect) {
+	for i := range c.Effects {
+		old := &c.Effects[i]
+		if old.Kind != e.Kind {
+			continue
+		}
+		switch effectRules[e.Kind].Stacking {
+		case StackRefresh:
+			old.Turns = longerTurns(old.Turns, e.Turns)
+			old.Potency = maxInt(old.Potency, e.Potency)
+		case StackIntensity:
+			old.Turns = longerTurns(old.Turns, e.Turns)
+			old.Potency += e.Potency
+		case StackDuration:
+			if old.Turns != Permanent && e.Turns != Permanent {
+				old.Turns += e.Turns
+			} else {
+				old.Turns = Permanent
+			}
+			old.Potency = maxInt(old.Potency, e.Potency)
+		}
+		return
+	}
+	c.Effects = append(c.Effects, e)
+}
+
+func (c *Character) RemoveEffect(kind EffectKind) {
+	for i, e := range c.Effects {
+		if e.Kind == kind {
+			c.Effects = append(c.Effects[:i], c.Effects[i+1:]...)
+			return
+		}
+	}
+}
+
+func (c *Character) HasEffect(kind EffectKind) bool {
+	for _, e := range c.Effects {
+		if e.Kind == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// speedModifier - product of the speed multipliers of every active effect
+func (c *Character) speedModifier() float64 {
+	modifier := 1.0
+	for _, e := range c.Effects {
+		modifier *= effectRules[e.Kind].Speed
+	}
+	return modifier
+}
+
+// DescribeEffects - short summary for the ui, like "poisoned(3) hasted(5)"
+func (c *Character) DescribeEffects() string {
+	names := make([]string, 0, len(c.Effects))
+	for _, e := range c.Effects {
+		if e.Turns == Permanent {
+			names = append(names, e.Kind.String())
+		} else {
+			names = append(names, fmt.Sprintf("%s(%d)", e.Kind, e.Turns))
+		}
+	}
+	return strings.Join(names, " ")
+}
+
+// tickEffects - runs one turn of c's effects, water starts and stops drowning
+// returns the cause of death if an effect killed c
+func (level *Level) tickEffects(c *Character) string {
+	if level.Map[c.Y][c.X].Symbol == Water {
+		if !c.HasEffect(Drowning) {
+			c.AddEffect(Effect{Drowning, Permanent, 1})
+		}
+	} else if c.HasEffect(Drowning) {
+		c.RemoveEffect(Drowning)
+		c.CurrentBreath = c.MaxBreath
+	}
+
+	remaining := c.Effects[:0]
+	for i, e := range c.Effects {
+		rule := effectRules[e.Kind]
+		if e.Kind == Drowning {
+			if c == &level.Player.Character {
+				level.AddEvent(fmt.Sprintf("Player has %d breath remaining", c.CurrentBreath))
+			}
+			c.CurrentBreath -= e.Potency
+			if c.CurrentBreath < 0 {
+				c.Hitpoints = 0
+			}
+		}
+		c.Hitpoints -= rule.Damage * e.Potency
+		// the dead stay dead, effects that haven't ticked yet are left as they are
+		if c.Hitpoints <= 0 {
+			c.Effects = append(remaining, c.Effects[i:]...)
+			return rule.Death
+		}
+		if rule.Heal > 0 {
+			c.Heal(rule.Heal * e.Potency)
+		}
+
+		if e.Turns != Permanent {
+			e.Turns--
+		}
+		if e.Turns != 0 {
+			remaining = append(remaining, e)
+		} else if c == &level.Player.Character {
+			level.AddEvent(fmt.Sprintf("%s is no longer %s", c.Name, rule.Name))
+		}
+	}
+	c.Effects = remaining
+	return ""
+}
+
+// longerTurns - the longer of two durations, Permanent beats everything
+func longerTurns(a, b int) int {
+	if a == Permanent || b == Permanent {
+		return Permanent
+	}
+	return maxInt(a, b)
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,134 @@
+package game
+
+import (
+	"fmt"
+	"math"
+)
+
+type EquipSlot int
+
+const (
+	SlotNone EquipSlot = iota
+	SlotHead
+	SlotBody
+	SlotHands
+	SlotWeapon
+	SlotOffhand
+	SlotLeftRing
+	SlotRightRing
+	NumEquipSlots
+)
+
+// slotNames - names used for slots in the item catalog, rings fit either ring slot
+var slotNames = map[string]EquipSlot{
+	"head":    SlotHead,
+	"body":    SlotBody,
+	"hands":   SlotHands,
+	"weapon":  SlotWeapon,
+	"offhand": SlotOffhand,
+	"ring":    SlotLeftRing,
+}
+
+func (slot EquipSlot) String() string {
+	switch slot {
+	case SlotHead:
+		return "head"
+	case SlotBody:
+		return "body"
+	case SlotHands:
+		return "hands"
+	case SlotWeapon:
+		return "weapon"
+	case SlotOffhand:
+		return "offhand"
+	case SlotLeftRing:
+		return "left ring"
+	case SlotRightRing:
+		return "right ring"
+	}
+	return "none"
+}
+
+// AttackPower - strength plus the attack bonus of everything worn
+func (c *Character) AttackPower() int {
+	attack := c.Strength
+	for _, item := range c.Equipment {
+		if item != nil {
+			attack += item.Attack
+		}
+	}
+	return attack
+}
+
+// Defense - damage absorbed from every hit
+func (c *Character) Defense() int {
+	defense := 0
+	for _, item := range c.Equipment {
+		if item != nil {
+			defense += item.Defense
+		}
+	}
+	return defense
+}
+
+// ActionSpeed - base speed plus equipment bonuses, never slower than a crawl
+func (c *Character) ActionSpeed() float64 {
+	speed := c.Speed
+	for _, item := range c.Equipment {
+		if item != nil {
+			speed += item.Speed
+		}
+	}
+	return math.Max(speed*c.speedModifier(), 0.1)
+}
+
+// equipSlotFor - the slot an item goes in, the second ring goes on the right hand
+func (c *Character) equipSlotFor(item *Item) EquipSlot {
+	if item.Slot == SlotLeftRing && c.Equipment[SlotLeftRing] != nil && c.Equipment[SlotRightRing] == nil {
+		return SlotRightRing
+	}
+	return item.Slot
+}
+
+// equip - wears the item in inventory slot, anything already worn there goes back in the pack
+// false if nothing was put on
+func (level *Level) equip(slot int) bool {
+	player := level.Player
+	if slot < 0 || slot >= len(player.Inventory) {
+		level.AddEvent("You have nothing to equip")
+		return false
+	}
+	item := player.Inventory[slot]
+	if item.Slot == SlotNone {
+		level.AddEvent(fmt.Sprintf("You can't equip the %s", item.Name))
+		return false
+	}
+	player.takeItem(slot)
+	equipSlot := player.equipSlotFor(item)
+	old := player.Equipment[equipSlot]
+	player.Equipment[equipSlot] = item
+	if old != nil {
+		player.Inventory = append(player.Inventory, old)
+		level.AddEvent(fmt.Sprintf("%s takes off %s", player.Name, old.Name))
+	}
+	level.AddEvent(fmt.Sprintf("%s equips %s", player.Name, item.Name))
+	return true
+}
+
+// unequip - takes off whatever is worn in equipSlot and puts it in the pack, false if nothing came off
+func (level *Level) unequip(equipSlot EquipSlot) bool {
+	player := level.Player
+	if equipSlot <= SlotNone || equipSlot >= NumEquipSlots || player.Equipment[equipSlot] == nil {
+		level.AddEvent("You aren't wearing anything there")
+		return false
+	}
+	if len(player.Inventory) >= MaxInventorySlots {
+		level.AddEvent("Your pack is full")
+		return false
+	}
+	item := player.Equipment[equipSlot]
+	player.Equipment[equipSlot] = nil
+	player.Inventory = append(player.Inventory, item)
+	level.AddEvent(fmt.Sprintf("%s takes off %s", player.Name, item.Name))
+	return true
+}
@@ -0,0 +1,53 @@
+package game
+
+import (
+	"fmt"
+)
+
+// Stat growth for every experience level gained
+const (
+	hitpointsPerLevel = 50
+	strengthPerLevel  = 2
+	breathPerLevel    = 1
+	sightEveryNLevels = 2 // sight range grows by one every other level
+)
+
+// ExperienceForLevel - total experience needed to reach level
+func ExperienceForLevel(level int) int {
+	return 50 * level * (level - 1)
+}
+
+// GainExperience - adds xp and levels up as many times as it pays for
+func (level *Level) GainExperience(xp int) {
+	player := level.Player
+	if xp <= 0 {
+		return
+	}
+	player.Experience += xp
+	for player.Experience >= ExperienceForLevel(player.ExperienceLevel+1) {
+		player.levelUp()
+		level.AddEvent(fmt.Sprintf("%s reaches level %d", player.Name, player.ExperienceLevel))
+	}
+}
+
+// levelUp - raises stats, hitpoints and breath grow and refill by the same amount
+func (player *Player) levelUp() {
+	player.ExperienceLevel++
+	player.MaxHitpoints += hitpointsPerLevel
+	player.Hitpoints += hitpointsPerLevel
+	player.Strength += strengthPerLevel
+	player.MaxBreath += breathPerLevel
+	player.CurrentBreath += breathPerLevel
+	if player.ExperienceLevel%sightEveryNLevels == 0 {
+		player.SightRange++
+	}
+}
+
+// experience - xp awarded for killing this monster
+func (m *Monster) experience() int {
+	def, exists := bestiary.Def(m.Kind)
+	if !exists {
+		return 0
+	}
+	return def.XP
+}
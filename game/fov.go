@@ -0,0 +1,126 @@
+package game
+
+import "math"
+
+// FOV - works out which positions can be seen from origin
+// implementations must not touch the map, monsters share them with the player
+type FOV interface {
+	Compute(level *Level, origin Pos, sightRange int) map[Pos]bool
+}
+
+// fov - used by every level, shadowcasting unless replaced with SetFOV
+var fov FOV = ShadowcastFOV{}
+
+// SetFOV - replaces the field of view algorithm used by every level
+func SetFOV(f FOV) {
+	fov = f
+}
+
+// BresenhamFOV - casts a ray to every tile within range
+// simple but not symmetric, a monster can see the player without the player seeing it
+type BresenhamFOV struct{}
+
+// Compute - iterates over a square the size of the sight range
+func (BresenhamFOV) Compute(level *Level, origin Pos, sightRange int) map[Pos]bool {
+	visible := make(map[Pos]bool)
+	for y := origin.Y - sightRange; y <= origin.Y+sightRange; y++ {
+		for x := origin.X - sightRange; x <= origin.X+sightRange; x++ {
+			xDelta := origin.X - x
+			yDelta := origin.Y - y
+			d := math.Sqrt(float64(xDelta*xDelta + yDelta*yDelta))
+			if d <= float64(sightRange) {
+				level.bresenhamVisibility(origin, Pos{x, y}, visible)
+			}
+		}
+	}
+	return visible
+}
+
+// ShadowcastFOV - symmetric recursive shadowcasting
+// scans each quadrant row by row, narrowing the visible slopes as walls cast shadows
+// floor tiles are only seen from positions they could see back, walls are seen whenever lit
+type ShadowcastFOV struct{}
+
+// slope - a rational slope num/den from the origin, den is always positive
+type slope struct {
+	num, den int
+}
+
+// shadowRow - the part of one row of a quadrant between two slopes
+type shadowRow struct {
+	depth      int
+	start, end slope
+}
+
+// quadrant - maps row and column within a quadrant back to map positions
+type quadrant struct {
+	origin Pos
+	dir    int // 0 north, 1 east, 2 south, 3 west
+}
+
+func (q quadrant) transform(depth, col int) Pos {
+	switch q.dir {
+	case 0:
+		return Pos{q.origin.X + col, q.origin.Y - depth}
+	case 1:
+		return Pos{q.origin.X + depth, q.origin.Y + col}
+	case 2:
+		return Pos{q.origin.X + col, q.origin.Y + depth}
+	default:
+		return Pos{q.origin.X - depth, q.origin.Y + col}
+	}
+}
+
+func (ShadowcastFOV) Compute(level *Level, origin Pos, sightRange int) map[Pos]bool {
+	visible := make(map[Pos]bool)
+	if !inRange(level, origin) {
+		return visible
+	}
+	visible[origin] = true
+	for dir := 0; dir < 4; dir++ {
+		q := quadrant{origin, dir}
+		scanRow(level, q, shadowRow{1, slope{-1, 1}, slope{1, 1}}, sightRange, visible)
+	}
+	return visible
+}
+
+// scanRow - reveals one row and recurses into the rows behind any gaps in it
+func scanRow(level *Level, q quadrant, row shadowRow, sightRange int, visible map[Pos]bool) {
+	if row.depth > sightRange {
+		return
+	}
+	minCol := floorDiv(2*row.depth*row.start.num+row.start.den, 2*row.start.den)
+	maxCol := -floorDiv(-(2*row.depth*row.end.num - row.end.den), 2*row.end.den)
+	hasPrev, prevWall := false, false
+	for col := minCol; col <= maxCol; col++ {
+		pos := q.transform(row.depth, col)
+		wall := !canSeeThrough(level, pos)
+		if (wall || row.symmetric(col)) && inRange(level, pos) && row.depth*row.depth+col*col <= sightRange*sightRange {
+			visible[pos] = true
+		}
+		if hasPrev && prevWall && !wall {
+			row.start = slope{2*col - 1, 2 * row.depth}
+		}
+		if hasPrev && !prevWall && wall {
+			next := shadowRow{row.depth + 1, row.start, slope{2*col - 1, 2 * row.depth}}
+			scanRow(level, q, next, sightRange, visible)
+		}
+		hasPrev, prevWall = true, wall
+	}
+	if hasPrev && !prevWall {
+		scanRow(level, q, shadowRow{row.depth + 1, row.start, row.end}, sightRange, visible)
+	}
+}
+
+// symmetric - true if col lies between the row's slopes, which is what makes floor visibility two way
+func (row shadowRow) symmetric(col int) bool {
+	return col*row.start.den >= row.depth*row.start.num && col*row.end.den <= row.depth*row.end.num
+}
+
+// floorDiv - integer division rounding towards negative infinity, b must be positive
+func floorDiv(a, b int) int {
+	if a < 0 {
+		return -((-a + b - 1) / b)
+	}
+	return a / b
+}
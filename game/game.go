@@ -2,56 +2,86 @@
 package game
 
 import (
-	"bufio"
-	"fmt"
+	"context"
+	"encoding/json"
 	"math"
-	"os"
-
-	"github.com/rdmulford/rirpg/worldgen"
-	"github.com/veandco/go-sdl2/sdl"
 )
 
 type Game struct {
-	LevelChans []chan *Level // send level state to multiple ui
-	InputChan  chan *Input   // recieve input from multiple ui
-	Level      *Level
+	InputChan   chan *Input // recieve input from multiple ui
+	World       *World
+	Level       *Level // level the player is currently on
+	State       GameState
+	subscribers subscribers   // uis sent a view of the level after every input
+	recorder    *json.Encoder // set by Record, writes every input handled
 }
 
-func NewGame(numWindows int, levelPath string) *Game {
-	levelChans := make([]chan *Level, numWindows)
-	for i := range levelChans {
-		levelChans[i] = make(chan *Level)
-	}
-	inputChan := make(chan *Input)
+// NewGame - loads the surface from levelPath, or generates it if levelPath is empty
+// every random thing in the game follows from seed, so the same seed and inputs always play out the same
+func NewGame(levelPath string, seed int64) (*Game, error) {
+	levelPaths := make(map[int]string)
 	if levelPath != "" {
-		return &Game{levelChans, inputChan, loadLevelFromFile(levelPath)}
-	} else {
-		return &Game{levelChans, inputChan, loadRandGenLevel()}
+		levelPaths[0] = levelPath
+	}
+	world, err := NewWorld(seed, levelPaths)
+	if err != nil {
+		return nil, err
 	}
+	return newGame(world), nil
+}
+
+func newGame(world *World) *Game {
+	inputChan := make(chan *Input, inputBuffer)
+	return &Game{InputChan: inputChan, World: world, Level: world.CurrentLevel(), State: Playing}
 }
 
+type GameState int
+
+const (
+	Playing GameState = iota
+	Dead
+	Victory
+	Paused
+)
+
 const (
 	None InputType = iota
 	Up
 	Down
 	Left
 	Right
+	Descend
+	Ascend
+	PickUp
+	Drop
+	Use
+	Equip
+	Unequip
+	Pause
+	Restart
 	QuitGame
 	CloseWindow
 	Search // TODO remove
+	UpLeft
+	UpRight
+	DownLeft
+	DownRight
 )
 
 type InputType int
 
 type Input struct {
-	Typ          InputType
-	LevelChannel chan *Level
+	Typ   InputType
+	Views <-chan *View // CloseWindow only, the window's subscription
+	Slot  int          // inventory slot for Drop, Use and Equip, EquipSlot for Unequip
+	Run   bool         // movement only, faster but much louder
 }
 
 type Tile struct {
 	Symbol  rune
 	Visible bool
 	Seen    bool
+	Light   float64 // how brightly lit the tile was when last seen, 0 to 1
 }
 
 const (
@@ -64,6 +94,10 @@ const (
 	Tree       rune = '^'
 	Water      rune = '~'
 	Sand       rune = '$'
+	Road       rune = '_'
+	Campfire   rune = '*'
+	UpStairs   rune = '<'
+	DownStairs rune = '>'
 	Pending    rune = -1
 )
 
@@ -80,32 +114,101 @@ type Entity struct {
 type Character struct {
 	Entity
 	Hitpoints     int
+	MaxHitpoints  int
 	Strength      int
+	Accuracy      int
+	Evasion       int
+	Damage        Dice // unarmed damage
 	Speed         float64
 	MaxBreath     int
 	CurrentBreath int
-	ActionPoints  float64
+	NextAction    float64 // game time of this character's next action
 	SightRange    int
+	Equipment     [NumEquipSlots]*Item
+	Effects       []Effect
+	Stealth       int // shrinks the distance monsters notice this character from
+	Light         int // radius of the light this character gives off
 }
 
 type Player struct {
 	Character
+	CauseOfDeath    string
+	Inventory       []*Item
+	Experience      int
+	ExperienceLevel int
+}
+
+func NewPlayer(p Pos) *Player {
+	player := &Player{}
+	player.Pos = p
+	player.Symbol = '@'
+	player.Name = "Riley"
+	player.Hitpoints = 1000
+	player.MaxHitpoints = player.Hitpoints
+	player.Strength = 20
+	player.Accuracy = 5
+	player.Evasion = 2
+	player.Damage = Dice{1, 4, 0}
+	player.Speed = 1.0
+	player.NextAction = 0
+	player.MaxBreath = 10
+	player.CurrentBreath = player.MaxBreath
+	player.SightRange = 20
+	player.Stealth = 3
+	player.Light = 3
+	player.ExperienceLevel = 1
+	return player
+}
+
+// Heal - restores up to amount hitpoints without going over max, returns the amount healed
+func (c *Character) Heal(amount int) int {
+	if c.Hitpoints+amount > c.MaxHitpoints {
+		amount = c.MaxHitpoints - c.Hitpoints
+	}
+	c.Hitpoints += amount
+	return amount
+}
+
+// Die - kills the player, cause is shown on the death screen
+func (player *Player) Die(cause string) {
+	if player.Hitpoints > 0 {
+		player.Hitpoints = 0
+	}
+	player.CauseOfDeath = cause
+}
+
+func (player *Player) IsDead() bool {
+	return player.Hitpoints <= 0
 }
 
 type Level struct {
-	Map      [][]Tile
-	Player   *Player
-	Monsters map[Pos]*Monster
-	Trees    map[Pos]Tile
-	Events   []string // TODO pull event into own struct
-	EventPos int
-	Debug    map[Pos]bool
+	Info         LevelInfo
+	Depth        int
+	Map          [][]Tile
+	Player       *Player
+	Start        Pos // where the player began, and where they arrive if there are no stairs to arrive on
+	Monsters     map[Pos]*Monster
+	Items        map[Pos][]*Item
+	Trees        map[Pos]Tile
+	Events       []string // TODO pull event into own struct
+	EventPos     int
+	Debug        map[Pos]string // notes for the debug overlay, like monster state changes
+	RNG          *RNG           // drives combat and loot on this level
+	AI           *RNG           // drives monster decisions on this level
+	Time         float64        // game time, monsters act when their next action comes before the player's
+	Turn         int            // whole turns of game time that have had their effects ticked
+	Connectivity Connectivity   // four or eight way movement, set for the whole game
+	flow         *flowFields
+	lights       *lightMap
+	visible      map[Pos]bool // tiles lineOfSight last marked visible
 }
 
-func (level *Level) Attack(c1, c2 *Character) {
-	c1.ActionPoints -= 1
-	c2.Hitpoints -= c1.Strength
-	level.AddEvent(fmt.Sprintf("%s(%d) Attacks %s(%d)", c1.Name, c1.Hitpoints, c2.Name, c2.Hitpoints))
+func (level *Level) Attack(c1, c2 *Character) AttackResult {
+	result := resolveAttack(c1, c2, level.RNG)
+	c2.Hitpoints -= result.Damage
+	level.AddEvent(describeAttack(c1, c2, result))
+	level.makeNoise(c2.Pos, combatNoise)
+	return result
 }
 
 func (level *Level) AddEvent(event string) {
@@ -117,24 +220,35 @@ func (level *Level) AddEvent(event string) {
 	}
 }
 
-// iterate over square the size of player sight range
+// lineOfSight - marks everything the player can see as visible and seen
+// only the tiles that were visible last time are reset, not the whole map
+// tiles in view also need enough light to be seen
 func (level *Level) lineOfSight() {
-	pos := level.Player.Pos
-	dist := level.Player.SightRange
-	for y := pos.Y - dist; y <= pos.Y+dist; y++ {
-		for x := pos.X - dist; x <= pos.X+dist; x++ {
-			xDelta := pos.X - x
-			yDelta := pos.Y - y
-			d := math.Sqrt(float64(xDelta*xDelta + yDelta*yDelta))
-			if d <= float64(dist) {
-				level.bresenhamVisibility(pos, Pos{x, y})
-			}
+	level.lights = nil
+	for pos := range level.visible {
+		level.Map[pos.Y][pos.X].Visible = false
+	}
+	inView := level.FieldOfView(&level.Player.Character)
+	light := level.lightLevels(inView)
+	level.visible = make(map[Pos]bool, len(inView))
+	for pos := range inView {
+		if !level.canMakeOut(level.Player.Pos, pos, light[pos]) {
+			continue
 		}
+		level.visible[pos] = true
+		level.Map[pos.Y][pos.X].Visible = true
+		level.Map[pos.Y][pos.X].Seen = true
+		level.Map[pos.Y][pos.X].Light = light[pos]
 	}
 }
 
-// bresenham adapted specifically to calculate FOW
-func (level *Level) bresenhamVisibility(start Pos, end Pos) {
+// FieldOfView - every position c can see from where it stands, the map is left untouched
+func (level *Level) FieldOfView(c *Character) map[Pos]bool {
+	return fov.Compute(level, c.Pos, c.SightRange)
+}
+
+// bresenham adapted specifically to calculate FOW, adds every position seen to visible
+func (level *Level) bresenhamVisibility(start Pos, end Pos, visible map[Pos]bool) {
 	steep := math.Abs(float64(end.Y-start.Y)) > math.Abs(float64(end.X-start.X))
 	if steep {
 		start.X, start.Y = start.Y, start.X
@@ -156,8 +270,10 @@ func (level *Level) bresenhamVisibility(start Pos, end Pos) {
 			} else {
 				pos = Pos{x, y}
 			}
-			level.Map[pos.Y][pos.X].Visible = true
-			level.Map[pos.Y][pos.X].Seen = true
+			if !inRange(level, pos) {
+				return
+			}
+			visible[pos] = true
 			if !canSeeThrough(level, pos) {
 				return
 			}
@@ -176,8 +292,10 @@ func (level *Level) bresenhamVisibility(start Pos, end Pos) {
 			} else {
 				pos = Pos{x, y}
 			}
-			level.Map[pos.Y][pos.X].Visible = true
-			level.Map[pos.Y][pos.X].Seen = true
+			if !inRange(level, pos) {
+				return
+			}
+			visible[pos] = true
 			if !canSeeThrough(level, pos) {
 				return
 			}
@@ -226,188 +344,6 @@ func bresenham(start Pos, end Pos) []Pos {
 	return result
 }
 
-func loadRandGenLevel() *Level {
-	xSize := 100
-	ySize := 100
-	genMap := worldgen.GenerateNewLevel(xSize, ySize, 100)
-
-	level := &Level{}
-	// TODO where we should init player?
-	level.Player = &Player{}
-	level.Player.Strength = 20
-	level.Player.Hitpoints = 1000
-	level.Player.Name = "Riley"
-	level.Player.Symbol = '@'
-	level.Player.Speed = 1.0
-	level.Player.ActionPoints = 0
-	level.Player.MaxBreath = 10
-	level.Player.CurrentBreath = level.Player.MaxBreath
-	level.Player.SightRange = 20
-
-	level.Map = make([][]Tile, ySize)
-	level.Monsters = make(map[Pos]*Monster)
-	level.Trees = make(map[Pos]Tile)
-	level.Events = make([]string, 10) // 10 = number of events that fit on screen at a time
-	level.Debug = make(map[Pos]bool)
-
-	for i := range level.Map {
-		level.Map[i] = make([]Tile, xSize) // refactor to jagged array?
-	}
-
-	for y := 0; y < len(level.Map); y++ {
-		line := genMap[y]
-		for x, c := range line {
-			var t Tile
-			switch c {
-			case ' ', '\t', '\n', '\r':
-				t.Symbol = Blank
-			case '#':
-				t.Symbol = StoneWall
-			case '|':
-				t.Symbol = ClosedDoor
-			case '/':
-				t.Symbol = OpenDoor
-			case '.':
-				t.Symbol = DirtFloor
-			case ',':
-				t.Symbol = Grass
-			case '^':
-				t.Symbol = Tree
-				level.Trees[Pos{x, y}] = t
-			case '~':
-				t.Symbol = Water
-			case '$':
-				t.Symbol = Sand
-			case '@':
-				level.Player.Y = y
-				level.Player.X = x
-				t.Symbol = Pending
-			case 'R':
-				level.Monsters[Pos{x, y}] = NewRat(Pos{x, y})
-				t.Symbol = Pending
-			case 'S':
-				level.Monsters[Pos{x, y}] = NewSpider(Pos{x, y})
-				t.Symbol = Pending
-			default:
-				panic("Invalid Character in Map")
-			}
-			level.Map[y][x] = t
-		}
-	}
-
-	// Handle pending tiles by setting floor to closest floor type found (or dirt for default)
-	for y, row := range level.Map {
-		for x, tile := range row {
-			if tile.Symbol == Pending {
-				level.Map[y][x] = level.bfsFloor(Pos{x, y})
-			}
-		}
-	}
-
-	level.lineOfSight()
-
-	return level
-}
-
-// loadLevelFromFile - reads in and parses a level file
-// properly associates each ascii character with a tile (not texture itself)
-func loadLevelFromFile(filename string) *Level {
-	file, err := os.Open(filename)
-	if err != nil {
-		panic(err)
-	}
-	defer file.Close()
-
-	scanner := bufio.NewScanner(file)
-	levelLines := make([]string, 0)
-	longestRow := 0
-	index := 0
-	for scanner.Scan() {
-		levelLines = append(levelLines, scanner.Text())
-		if len(levelLines[index]) > longestRow {
-			longestRow = len(levelLines[index])
-		}
-		index++
-	}
-
-	level := &Level{}
-	// TODO where we should init player?
-	level.Player = &Player{}
-	level.Player.Strength = 20
-	level.Player.Hitpoints = 1000
-	level.Player.Name = "Riley"
-	level.Player.Symbol = '@'
-	level.Player.Speed = 1.0
-	level.Player.ActionPoints = 0
-	level.Player.MaxBreath = 10
-	level.Player.CurrentBreath = level.Player.MaxBreath
-	level.Player.SightRange = 20
-
-	level.Map = make([][]Tile, len(levelLines))
-	level.Monsters = make(map[Pos]*Monster)
-	level.Trees = make(map[Pos]Tile)
-	level.Events = make([]string, 10) // 10 = number of events that fit on screen at a time
-	level.Debug = make(map[Pos]bool)
-
-	for i := range level.Map {
-		level.Map[i] = make([]Tile, longestRow) // refactor to jagged array?
-	}
-
-	for y := 0; y < len(level.Map); y++ {
-		line := levelLines[y]
-		for x, c := range line {
-			var t Tile
-			switch c {
-			case ' ', '\t', '\n', '\r':
-				t.Symbol = Blank
-			case '#':
-				t.Symbol = StoneWall
-			case '|':
-				t.Symbol = ClosedDoor
-			case '/':
-				t.Symbol = OpenDoor
-			case '.':
-				t.Symbol = DirtFloor
-			case ',':
-				t.Symbol = Grass
-			case '^':
-				t.Symbol = Tree
-				level.Trees[Pos{x, y}] = t
-			case '~':
-				t.Symbol = Water
-			case '$':
-				t.Symbol = Sand
-			case '@':
-				level.Player.Y = y
-				level.Player.X = x
-				t.Symbol = Pending
-			case 'R':
-				level.Monsters[Pos{x, y}] = NewRat(Pos{x, y})
-				t.Symbol = Pending
-			case 'S':
-				level.Monsters[Pos{x, y}] = NewSpider(Pos{x, y})
-				t.Symbol = Pending
-			default:
-				panic("Invalid Character in Map")
-			}
-			level.Map[y][x] = t
-		}
-	}
-
-	// Handle pending tiles by setting floor to closest floor type found (or dirt for default)
-	for y, row := range level.Map {
-		for x, tile := range row {
-			if tile.Symbol == Pending {
-				level.Map[y][x] = level.bfsFloor(Pos{x, y})
-			}
-		}
-	}
-
-	level.lineOfSight()
-
-	return level
-}
-
 // inRange checks that X pos and Y pos are within range of the map
 func inRange(level *Level, pos Pos) bool {
 	return pos.X < len(level.Map[0]) && pos.Y < len(level.Map) && pos.X >= 0 && pos.Y >= 0
@@ -416,14 +352,22 @@ func inRange(level *Level, pos Pos) bool {
 // canWalk - determine if a tile should result in a collision or not
 // possibly rename to be more general? (used in astar)
 func canWalk(level *Level, pos Pos) bool {
+	if canPass(level, pos) {
+		_, exists := level.Monsters[pos]
+		return !exists
+	}
+	return false
+}
+
+// canPass - like canWalk but ignores monsters, used for maps shared by every monster
+func canPass(level *Level, pos Pos) bool {
 	if inRange(level, pos) {
 		t := level.Map[pos.Y][pos.X]
 		switch t.Symbol {
-		case StoneWall, ClosedDoor, Tree, Blank:
+		case StoneWall, ClosedDoor, Tree, Blank, Campfire:
 			return false
 		}
-		_, exists := level.Monsters[pos]
-		return !exists
+		return true
 	}
 	return false
 }
@@ -443,10 +387,14 @@ func canSeeThrough(level *Level, pos Pos) bool {
 
 // checkDoor - open a closed door
 func checkDoor(level *Level, pos Pos) {
+	if !inRange(level, pos) {
+		return
+	}
 	t := level.Map[pos.Y][pos.X]
 	if t.Symbol == ClosedDoor {
 		level.Map[pos.Y][pos.X].Symbol = OpenDoor
 		level.lineOfSight()
+		level.makeNoise(pos, doorNoise)
 	}
 }
 
@@ -455,91 +403,151 @@ func (level *Level) resolveMovement(pos Pos) {
 	if exists {
 		level.Attack(&level.Player.Character, &monster.Character)
 		if monster.Hitpoints <= 0 {
-			delete(level.Monsters, monster.Pos)
-			level.AddEvent(fmt.Sprintf("%s is dead", monster.Name))
-		}
-		if level.Player.Hitpoints <= 0 {
-			level.AddEvent("Player died")
-			sdl.Quit()
-			os.Exit(1)
+			level.killMonster(monster)
+			level.GainExperience(monster.experience())
 		}
 	} else if canWalk(level, pos) {
 		level.Player.Move(pos, level)
 	} else {
 		checkDoor(level, pos)
 	}
-
-	// Check if player is drowning
-	if level.Map[level.Player.Pos.Y][level.Player.Pos.X].Symbol == '~' {
-		level.AddEvent(fmt.Sprintf("Player has %d breath remaining", level.Player.CurrentBreath))
-		level.Player.CurrentBreath -= 1
-		if level.Player.CurrentBreath < 0 {
-			level.AddEvent("Player died")
-			sdl.Quit()
-			os.Exit(1)
-		}
-	} else {
-		level.Player.CurrentBreath = level.Player.MaxBreath
-	}
 }
 
 func (player *Player) Move(to Pos, level *Level) {
 	player.Pos = to
-	for y, row := range level.Map {
-		for x, _ := range row {
-			level.Map[y][x].Visible = false
-		}
-	}
 	level.lineOfSight()
 }
 
 // handleInput - takes an input and performs a game action
+// which inputs are accepted depends on the game state
 func (game *Game) handleInput(input *Input) {
+	if input.Typ == CloseWindow {
+		game.Unsubscribe(input.Views)
+		return
+	}
+	switch game.State {
+	case Playing:
+		game.handlePlayingInput(input)
+	case Paused:
+		if input.Typ == Pause {
+			game.State = Playing
+		}
+	case Dead, Victory:
+		if input.Typ == Restart {
+			game.restart()
+		}
+	}
+}
+
+func (game *Game) handlePlayingInput(input *Input) {
 	level := game.Level
+	// the debug overlay only shows what happened since the player's last action
+	level.Debug = make(map[Pos]string)
+	from := level.Player.Pos
+	acted := true
 	switch input.Typ {
-	case Up:
-		level.resolveMovement(Pos{level.Player.X, level.Player.Y - 1})
-	case Down:
-		level.resolveMovement(Pos{level.Player.X, level.Player.Y + 1})
-	case Left:
-		level.resolveMovement(Pos{level.Player.X - 1, level.Player.Y})
-	case Right:
-		level.resolveMovement(Pos{level.Player.X + 1, level.Player.Y})
-	case CloseWindow:
-		close(input.LevelChannel)
-		chanIndex := 0
-		for i, c := range game.LevelChans {
-			if c == input.LevelChannel {
-				chanIndex = i
-				break
-			}
+	case Up, Down, Left, Right, UpLeft, UpRight, DownLeft, DownRight:
+		dir := inputDirections[input.Typ]
+		to := Pos{level.Player.X + dir.X, level.Player.Y + dir.Y}
+		// diagonals that aren't allowed here and stepping off the map don't use up a turn
+		if !inRange(level, to) || !level.canStep(level.Player.Pos, to) {
+			return
 		}
-		// remove channel from slice
-		game.LevelChans = append(game.LevelChans[:chanIndex], game.LevelChans[chanIndex+1:]...)
+		level.resolveMovement(to)
+	case Descend:
+		acted = game.takeStairs(DownStairs, 1)
+	case Ascend:
+		acted = game.takeStairs(UpStairs, -1)
+	case PickUp:
+		acted = level.pickUp()
+	case Drop:
+		acted = level.drop(input.Slot)
+	case Use:
+		acted = level.use(input.Slot)
+	case Equip:
+		acted = level.equip(input.Slot)
+	case Unequip:
+		acted = level.unequip(EquipSlot(input.Slot))
+	case Pause:
+		game.State = Paused
+		return
+	default:
+		return
+	}
+	// nothing happened, so the monsters don't get a free turn
+	if !acted {
+		return
+	}
+
+	// footsteps can be heard by nearby monsters, running is quicker but louder
+	// stepping onto a new tile takes as long as the terrain makes it
+	// attacks, doors and bumping into walls take a whole action, running or not
+	cost := ActionCost
+	if input.Typ.isMovement() && level.Player.Pos != from {
+		cost = stepTime(level, level.Player.Pos)
+		noise := walkNoise
+		if input.Run {
+			noise = runNoise
+			cost *= runCost
+		}
+		level.makeNoise(level.Player.Pos, noise-level.Player.Stealth)
 	}
-}
 
-// getNeighbors - returns an array containing the positions of each neighboring tile
-func getNeighbors(level *Level, pos Pos) []Pos {
-	neighbors := make([]Pos, 0, 4)
-	left := Pos{pos.X - 1, pos.Y}
-	right := Pos{pos.X + 1, pos.Y}
-	up := Pos{pos.X, pos.Y - 1}
-	down := Pos{pos.X, pos.Y + 1}
+	// doors, items and the player may have changed, so every flow field and the light are stale
+	game.Level.flow = nil
+	game.Level.lights = nil
 
-	if canWalk(level, right) {
-		neighbors = append(neighbors, right)
-	}
-	if canWalk(level, left) {
-		neighbors = append(neighbors, left)
+	// everything that acts before the player's next action gets to move
+	game.Level.Player.spendTime(cost)
+	game.Level.runUntilPlayer()
+	// light changes as monsters move and the day goes by
+	game.Level.lineOfSight()
+
+	if game.Level.Player.IsDead() {
+		game.State = Dead
+	} else if game.World.VictoryDepth > 0 && game.World.Depth >= game.World.VictoryDepth {
+		game.State = Victory
 	}
-	if canWalk(level, up) {
-		neighbors = append(neighbors, up)
+}
+
+// restart - throws away the world and starts again from the surface
+func (game *Game) restart() {
+	world, err := NewWorld(game.World.Seed, game.World.LevelPaths)
+	if err != nil {
+		game.Level.AddEvent(err.Error())
+		return
+	}
+	world.VictoryDepth = game.World.VictoryDepth
+	world.SetConnectivity(game.World.Connectivity)
+	game.World = world
+	game.Level = world.CurrentLevel()
+	game.State = Playing
+}
+
+// takeStairs - moves the player between levels if they are standing on stairs, false if they don't go anywhere
+func (game *Game) takeStairs(stairs rune, delta int) bool {
+	level := game.Level
+	if level.Map[level.Player.Y][level.Player.X].Symbol != stairs {
+		level.AddEvent("There are no stairs here")
+		return false
 	}
-	if canWalk(level, down) {
-		neighbors = append(neighbors, down)
+	err := game.World.ChangeDepth(delta)
+	if err != nil {
+		level.AddEvent(err.Error())
+		return false
 	}
+	game.Level = game.World.CurrentLevel()
+	return true
+}
 
+// getNeighbors - returns an array containing the positions of each neighboring tile
+func getNeighbors(level *Level, pos Pos) []Pos {
+	neighbors := make([]Pos, 0, 8)
+	for _, next := range level.steps(pos) {
+		if canWalk(level, next) {
+			neighbors = append(neighbors, next)
+		}
+	}
 	return neighbors
 }
 
@@ -557,18 +565,9 @@ func (pos *Pos) IsNextTo(tile Tile, level *Level) bool {
 	return false
 }
 
+// IsNextToPlayer - true if the player is a single step away, diagonals count on eight way levels
 func (pos *Pos) IsNextToPlayer(level *Level) bool {
-	left := Pos{pos.X - 1, pos.Y}
-	right := Pos{pos.X + 1, pos.Y}
-	up := Pos{pos.X, pos.Y - 1}
-	down := Pos{pos.X, pos.Y + 1}
-	if left == level.Player.Pos ||
-		right == level.Player.Pos ||
-		up == level.Player.Pos ||
-		down == level.Player.Pos {
-		return true
-	}
-	return false
+	return level.canStep(*pos, level.Player.Pos)
 }
 
 // bfs - classic breadth first search implementation
@@ -583,11 +582,11 @@ func (level *Level) bfsFloor(start Pos) Tile {
 		currentTile := level.Map[current.Y][current.X]
 		switch currentTile.Symbol {
 		case DirtFloor:
-			return Tile{DirtFloor, false, false}
+			return Tile{Symbol: DirtFloor}
 		case Grass:
-			return Tile{Grass, false, false}
+			return Tile{Symbol: Grass}
 		case Sand:
-			return Tile{Sand, false, false}
+			return Tile{Symbol: Sand}
 		default:
 		}
 		// new slice starting from second element to the end
@@ -600,11 +599,20 @@ func (level *Level) bfsFloor(start Pos) Tile {
 			}
 		}
 	}
-	return Tile{DirtFloor, false, false}
+	return Tile{Symbol: DirtFloor}
 }
 
-// astar - classic astar implementation
+// astarNodeLimit - positions a search may expand before giving up
+// monsters only search for nearby goals, without a limit an unreachable one floods the whole map
+const astarNodeLimit = 1000
+
+// astar - classic astar implementation, nil if goal can't be reached within astarNodeLimit steps of searching
 func (level *Level) astar(start Pos, goal Pos) []Pos {
+	return level.astarWithin(start, goal, astarNodeLimit)
+}
+
+// astarWithin - astar expanding at most limit positions
+func (level *Level) astarWithin(start Pos, goal Pos, limit int) []Pos {
 	frontier := make(pqueue, 0, 8)
 	frontier = frontier.push(start, 1)
 	cameFrom := make(map[Pos]Pos)
@@ -613,7 +621,7 @@ func (level *Level) astar(start Pos, goal Pos) []Pos {
 	costSoFar[start] = 0
 	var current Pos
 
-	for len(frontier) > 0 {
+	for expanded := 0; len(frontier) > 0 && expanded < limit; expanded++ {
 		frontier, current = frontier.pop()
 
 		// found path
@@ -633,13 +641,17 @@ func (level *Level) astar(start Pos, goal Pos) []Pos {
 		}
 
 		for _, next := range getNeighbors(level, current) {
-			newCost := costSoFar[current] + 1 // always 1 for now
+			newCost := costSoFar[current] + tileCost(level, next)
 			_, exists := costSoFar[next]
 			if !exists || newCost < costSoFar[next] {
 				costSoFar[next] = newCost
 				xDist := int(math.Abs(float64(goal.X - next.X)))
 				yDist := int(math.Abs(float64(goal.Y - next.Y)))
-				priority := newCost + xDist + yDist
+				steps := xDist + yDist
+				if level.Connectivity == EightWay {
+					steps = int(math.Max(float64(xDist), float64(yDist)))
+				}
+				priority := newCost + steps*minTileCost
 				frontier = frontier.push(next, priority)
 				cameFrom[next] = current
 			}
@@ -648,33 +660,41 @@ func (level *Level) astar(start Pos, goal Pos) []Pos {
 	return nil
 }
 
-// Run - contains main game loop
-func (game *Game) Run() {
-	for _, lchan := range game.LevelChans {
-		lchan <- game.Level
-	}
+// Run - contains main game loop, returns when ctx is cancelled, on QuitGame or once the last window closes
+// view channels are closed when the game stops so uis know to exit
+func (game *Game) Run(ctx context.Context) {
+	defer game.subscribers.stop()
+	defer game.stopRecording()
+
+	game.broadcast()
+
+	for {
+		var input *Input
+		select {
+		case <-ctx.Done():
+			return
+		case input = <-game.InputChan:
+		}
 
-	for input := range game.InputChan {
 		// quit game
 		if input.Typ == QuitGame {
 			return
 		}
 
+		game.record(input)
 		game.handleInput(input)
 
-		// move monsters towards player
-		for _, monster := range game.Level.Monsters {
-			monster.Update(game.Level)
-		}
-
 		// all windows have been closed
-		if len(game.LevelChans) == 0 {
+		if input.Typ == CloseWindow && game.Subscribers() == 0 {
 			return
 		}
 
-		// update game level
-		for _, lchan := range game.LevelChans {
-			lchan <- game.Level
-		}
+		game.broadcast()
 	}
 }
+
+// broadcast - sends a snapshot of the current level to every ui
+// the uis only ever see the snapshot, never the level the game goes on changing
+func (game *Game) broadcast() {
+	game.subscribers.publish(game.Level.Snapshot(game.State))
+}
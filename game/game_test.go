@@ -1,37 +1,1852 @@
 package game
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestLoadLevelFromFile(t *testing.T) {
+	level, err := LoadLevelFromFile("maps/level1.map")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(level.Map) != 50 || len(level.Map[0]) != 50 {
+		t.Errorf("expected 50x50 map, got %dx%d", len(level.Map[0]), len(level.Map))
+	}
+	if level.Player.Pos != (Pos{39, 13}) {
+		t.Errorf("expected player at {39 13}, got %v", level.Player.Pos)
+	}
+	if len(level.Monsters) == 0 {
+		t.Error("expected monsters to be loaded")
+	}
+	if level.Info.Version != 0 || level.Info.Width != 50 || level.Info.Height != 50 {
+		t.Errorf("expected headerless file to load as version 0, got %+v", level.Info)
+	}
 
+	if _, err := LoadLevelFromFile("maps/does-not-exist.map"); err == nil {
+		t.Error("expected error for missing file")
+	}
+}
+
+func TestLoadLevel(t *testing.T) {
+	tests := []struct {
+		name      string
+		level     string
+		err       error
+		line, col int
+	}{
+		{"valid", "###\n#@#\n###\n", nil, 0, 0},
+		{"monsters", "####\n#@RS\n####\n", nil, 0, 0},
+		{"empty", "", ErrEmptyLevel, 0, 0},
+		{"invalid char", "###\n#@X\n###\n", ErrInvalidChar, 2, 3},
+		{"ragged short", "###\n#@\n###\n", ErrRaggedRow, 2, 3},
+		{"ragged long", "###\n#@..\n###\n", ErrRaggedRow, 2, 4},
+		{"no player", "###\n#.#\n###\n", ErrNoPlayer, 0, 0},
+		{"two players", "####\n#@@#\n####\n", ErrMultiplePlayer, 2, 3},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			level, err := LoadLevel(strings.NewReader(tc.level))
+			if tc.err == nil {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if level.Map[level.Player.Y][level.Player.X].Symbol == Pending {
+					t.Error("player tile left pending")
+				}
+				return
+			}
+			var levelErr *LevelError
+			if !errors.As(err, &levelErr) {
+				t.Fatalf("expected *LevelError, got %v", err)
+			}
+			if !errors.Is(err, tc.err) {
+				t.Errorf("expected %v, got %v", tc.err, err)
+			}
+			if levelErr.Line != tc.line || levelErr.Col != tc.col {
+				t.Errorf("expected %d:%d, got %d:%d", tc.line, tc.col, levelErr.Line, levelErr.Col)
+			}
+		})
+	}
+}
+
+func TestLoadLevelVersioned(t *testing.T) {
+	level, err := LoadLevelFromFile("maps/cellar.map")
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := level.Info
+	if info.Version != 1 || info.Name != "The Cellar" || info.Author != "Riley" ||
+		info.Width != 20 || info.Height != 8 || info.Seed != 7 || info.Ambient != 0.3 || info.Music != "damp" {
+		t.Errorf("unexpected header %+v", info)
+	}
+	rat, exists := level.Monsters[Pos{3, 3}]
+	if !exists || rat.Name != "Rat" {
+		t.Errorf("expected custom glyph to spawn a rat, got %v", rat)
+	}
+	if level.Map[3][3].Symbol != DirtFloor {
+		t.Errorf("expected rat to stand on dirt, got %q", level.Map[3][3].Symbol)
+	}
+	if level.Map[2][12].Symbol != Water {
+		t.Errorf("expected custom tile glyph to be water, got %q", level.Map[2][12].Symbol)
+	}
+	if items := level.Items[Pos{14, 1}]; len(items) != 1 || items[0].Kind != "potion" {
+		t.Errorf("expected a potion at {14 1}, got %v", items)
+	}
+
+	tests := []struct {
+		name  string
+		level string
+		err   error
+		line  int
+	}{
+		{"future version", "version 99\nmap\n#@#\n", ErrUnsupportedVersion, 1},
+		{"unknown key", "version 1\ncolour red\nmap\n#@#\n", ErrBadHeader, 2},
+		{"bad seed", "version 1\nseed abc\nmap\n#@#\n", ErrBadHeader, 2},
+		{"unknown tile", "version 1\nlegend\n= tile lava\nmap\n#@#\n", ErrUnknownTile, 3},
+		{"unknown monster", "version 1\nlegend\nd monster dragon\nmap\n#@#\n", ErrUnknownMonster, 3},
+		{"bad legend", "version 1\nlegend\nab tile water\nmap\n#@#\n", ErrBadLegend, 3},
+		{"size mismatch", "version 1\nsize 4 1\nmap\n#@#\n", ErrSizeMismatch, 4},
+		{"error line offset", "version 1\nmap\n#@#\n#X#\n", ErrInvalidChar, 4},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := LoadLevel(strings.NewReader(tc.level))
+			var levelErr *LevelError
+			if !errors.As(err, &levelErr) {
+				t.Fatalf("expected *LevelError, got %v", err)
+			}
+			if !errors.Is(err, tc.err) || levelErr.Line != tc.line {
+				t.Errorf("expected %v on line %d, got %v", tc.err, tc.line, err)
+			}
+		})
+	}
+}
+
+func TestGenerateLevel(t *testing.T) {
+	level, err := GenerateLevel(50, 50, 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(level.Map) != 50 || len(level.Map[0]) != 50 {
+		t.Errorf("expected 50x50 map, got %dx%d", len(level.Map[0]), len(level.Map))
+	}
+}
+
+func TestParseDice(t *testing.T) {
+	tests := []struct {
+		s    string
+		dice Dice
+		ok   bool
+	}{
+		{"1d6", Dice{1, 6, 0}, true},
+		{"2d8+3", Dice{2, 8, 3}, true},
+		{"3d4-1", Dice{3, 4, -1}, true},
+		{"d6", Dice{}, false},
+		{"0d6", Dice{}, false},
+		{"2d", Dice{}, false},
+		{"2x6", Dice{}, false},
+	}
+	for _, tc := range tests {
+		dice, err := ParseDice(tc.s)
+		if (err == nil) != tc.ok || (tc.ok && dice != tc.dice) {
+			t.Errorf("ParseDice(%q) = %v, %v", tc.s, dice, err)
+		}
+		if tc.ok && dice.String() != tc.s {
+			t.Errorf("expected %v to print as %q", dice, tc.s)
+		}
+	}
+
+	r := NewRNG(1)
+	for i := 0; i < 100; i++ {
+		if roll := (Dice{2, 6, 1}).Roll(r); roll < 3 || roll > 13 {
+			t.Fatalf("2d6+1 rolled %d", roll)
+		}
+	}
+}
+
+func TestAttack(t *testing.T) {
+	level, err := LoadLevel(strings.NewReader("####\n#@R#\n####\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	player := &level.Player.Character
+	rat := &level.Monsters[Pos{2, 1}].Character
+
+	// same seed, same fight
+	results := make([]AttackResult, 0)
+	level.RNG = NewRNG(42)
+	for i := 0; i < 50; i++ {
+		results = append(results, resolveAttack(player, rat, level.RNG))
+	}
+	level.RNG = NewRNG(42)
+	for i := 0; i < 50; i++ {
+		if result := resolveAttack(player, rat, level.RNG); result != results[i] {
+			t.Fatalf("attack %d not reproducible, %+v vs %+v", i, result, results[i])
+		}
+	}
+
+	hits := 0
+	for _, result := range results {
+		switch {
+		case result.Roll == 1 && result.Hit:
+			t.Error("a roll of 1 should always miss")
+		case result.Roll == 20 && !result.Critical:
+			t.Error("a roll of 20 should always crit")
+		case result.Hit && !result.Critical && (result.Damage < 1+20 || result.Damage > 4+20):
+			t.Errorf("expected 1d4+20 damage, got %d", result.Damage)
+		case result.Critical && (result.Damage < 2*(1+20) || result.Damage > 2*(4+20)):
+			t.Errorf("expected double damage on a crit, got %d", result.Damage)
+		}
+		if result.Hit {
+			hits++
+		}
+	}
+	if hits == 0 || hits == len(results) {
+		t.Errorf("expected a mix of hits and misses, got %d hits", hits)
+	}
+
+	// only crits get through impossible evasion
+	rat.Evasion = 100
+	for i := 0; i < 100; i++ {
+		if result := resolveAttack(player, rat, level.RNG); result.Hit != result.Critical {
+			t.Fatalf("expected only crits to land, got %+v", result)
+		}
+	}
+
+	hitpoints := rat.Hitpoints
+	result := level.Attack(player, rat)
+	if rat.Hitpoints != hitpoints-result.Damage {
+		t.Error("expected attack to apply damage")
+	}
+	if result.Hit && !strings.Contains(level.Events[level.EventPos-1], "for") {
+		t.Errorf("expected damage in event, got %q", level.Events[level.EventPos-1])
+	} else if !result.Hit && level.Events[level.EventPos-1] != "Riley misses Rat" {
+		t.Errorf("expected miss event, got %q", level.Events[level.EventPos-1])
+	}
+}
+
+// driverCase - a small level, the inputs played on it and what should have happened
+type driverCase struct {
+	name   string
+	level  string
+	inputs []InputType
+	check  func(t *testing.T, d *Driver)
+}
+
+func runDriverCases(t *testing.T, tests []driverCase) {
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			level, err := LoadLevel(strings.NewReader(tc.level))
+			if err != nil {
+				t.Fatal(err)
+			}
+			d := NewLevelDriver(level)
+			d.Press(tc.inputs...)
+			tc.check(t, d)
+		})
+	}
 }
 
 func TestCanWalk(t *testing.T) {
+	level, err := LoadLevel(strings.NewReader("######\n#@.|/#\n#~R^$#\n######\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	walkable := []struct {
+		pos  Pos
+		want bool
+	}{
+		{Pos{2, 1}, true},
+		{Pos{0, 0}, false},
+		{Pos{3, 1}, false},
+		{Pos{4, 1}, true},
+		{Pos{1, 2}, true},
+		{Pos{2, 2}, false},
+		{Pos{3, 2}, false},
+		{Pos{4, 2}, true},
+		{Pos{-1, 1}, false},
+		{Pos{6, 1}, false},
+	}
+	for _, tc := range walkable {
+		if got := canWalk(level, tc.pos); got != tc.want {
+			t.Errorf("canWalk %v: expected %v, got %v", tc.pos, tc.want, got)
+		}
+	}
 
+	runDriverCases(t, []driverCase{
+		{"move", "#####\n#@..#\n#####\n", []InputType{Right, Right}, func(t *testing.T, d *Driver) {
+			if d.Player().Pos != (Pos{3, 1}) {
+				t.Errorf("expected player at {3 1}, got %v", d.Player().Pos)
+			}
+		}},
+		{"wall", "#####\n#@..#\n#####\n", []InputType{Up, Left}, func(t *testing.T, d *Driver) {
+			if d.Player().Pos != (Pos{1, 1}) {
+				t.Errorf("expected walls to stop the player, got %v", d.Player().Pos)
+			}
+		}},
+		{"edge of map", "@.\n..\n", []InputType{Left, Up, Right, Down}, func(t *testing.T, d *Driver) {
+			if d.Player().Pos != (Pos{1, 1}) {
+				t.Errorf("expected player to stay on the map at {1 1}, got %v", d.Player().Pos)
+			}
+		}},
+		{"diagonal needs eight way", "#####\n#@..#\n#...#\n#####\n", []InputType{DownRight}, func(t *testing.T, d *Driver) {
+			if d.Player().Pos != (Pos{1, 1}) || d.Level().Time != 0 {
+				t.Errorf("expected diagonal to be refused without using time, got %v at %v", d.Player().Pos, d.Level().Time)
+			}
+		}},
+	})
 }
 
 func TestCheckDoor(t *testing.T) {
+	level, err := LoadLevel(strings.NewReader("####\n#@.#\n####\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkDoor(level, Pos{2, 1})
+	checkDoor(level, Pos{-1, 5})
+	if level.Map[1][2].Symbol != DirtFloor {
+		t.Error("expected floor to be left alone")
+	}
 
+	runDriverCases(t, []driverCase{
+		{"open door", "#####\n#@|.#\n#####\n", []InputType{Right}, func(t *testing.T, d *Driver) {
+			if d.Player().Pos != (Pos{1, 1}) || d.Level().Map[1][2].Symbol != OpenDoor {
+				t.Errorf("expected bumping the door to open it, got %q with player at %v", d.Level().Map[1][2].Symbol, d.Player().Pos)
+			}
+		}},
+		{"walk through door", "#####\n#@|.#\n#####\n", []InputType{Right, Right, Right}, func(t *testing.T, d *Driver) {
+			if d.Player().Pos != (Pos{3, 1}) {
+				t.Errorf("expected player through the door at {3 1}, got %v", d.Player().Pos)
+			}
+		}},
+		{"door opens up the view", "######\n#@|..#\n######\n", []InputType{Right}, func(t *testing.T, d *Driver) {
+			if !d.Level().Map[1][4].Visible {
+				t.Error("expected to see through the open door")
+			}
+		}},
+	})
 }
 
 func TestHandleInput(t *testing.T) {
+	g, err := NewGame("maps/cellar.map", 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.World.VictoryDepth = 2
+	player := g.Level.Player
+
+	g.handleInput(&Input{Typ: Pause})
+	g.handleInput(&Input{Typ: Right})
+	if g.State != Paused || player.Pos != (Pos{1, 1}) {
+		t.Fatalf("expected paused game to ignore movement, got %v at %v", g.State, player.Pos)
+	}
+	g.handleInput(&Input{Typ: Pause})
+	g.handleInput(&Input{Typ: Right})
+	if g.State != Playing || player.Pos != (Pos{2, 1}) {
+		t.Fatalf("expected to move after unpausing, got %v at %v", g.State, player.Pos)
+	}
+
+	// hold the player under water until they drown
+	g.Level.Map[1][3].Symbol = Water
+	g.handleInput(&Input{Typ: Right})
+	for i := 0; i < player.MaxBreath; i++ {
+		g.handleInput(&Input{Typ: Up})
+	}
+	if g.State != Dead || player.CauseOfDeath != "drowned" {
+		t.Fatalf("expected player to drown, got %v %q", g.State, player.CauseOfDeath)
+	}
+	g.handleInput(&Input{Typ: Left})
+	if player.Pos != (Pos{3, 1}) {
+		t.Error("expected dead player not to move")
+	}
 
+	g.handleInput(&Input{Typ: Restart})
+	if g.State != Playing || g.Level.Player == player || g.Level.Player.IsDead() {
+		t.Fatal("expected restart to start a fresh game")
+	}
+	if g.World.VictoryDepth != 2 {
+		t.Error("expected restart to keep victory depth")
+	}
+
+	g.Level.Player.Pos = Pos{18, 6}
+	g.handleInput(&Input{Typ: Descend})
+	if g.State != Playing {
+		t.Fatalf("expected to still be playing on depth 1, got %v", g.State)
+	}
+	stairs, _ := g.Level.findTile(DownStairs)
+	g.Level.Player.Move(stairs, g.Level)
+	g.handleInput(&Input{Typ: Descend})
+	if g.State != Victory {
+		t.Errorf("expected victory on reaching depth 2, got %v", g.State)
+	}
 }
 
 func TestGetNeighbors(t *testing.T) {
-
+	level, err := LoadLevel(strings.NewReader("#####\n#.#.#\n#.@R#\n#...#\n#####\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	neighbors := getNeighbors(level, Pos{2, 2})
+	if len(neighbors) != 2 || neighbors[0] == neighbors[1] {
+		t.Fatalf("expected two neighbors past the wall and rat, got %v", neighbors)
+	}
+	for _, pos := range neighbors {
+		if pos != (Pos{1, 2}) && pos != (Pos{2, 3}) {
+			t.Errorf("unexpected neighbor %v", pos)
+		}
+	}
+	level.Connectivity = EightWay
+	if neighbors := getNeighbors(level, Pos{2, 2}); len(neighbors) != 4 {
+		t.Errorf("expected diagonals too on eight way levels, got %v", neighbors)
+	}
 }
 
 func TestBfs(t *testing.T) {
-
+	tests := []struct {
+		name  string
+		level string
+		want  rune
+	}{
+		{"dirt", "#####\n#.@.#\n#####\n", DirtFloor},
+		{"grass", "#####\n#,@,#\n#####\n", Grass},
+		{"sand", "#####\n#$@$#\n#####\n", Sand},
+		{"walled in", "###\n#@#\n###\n", DirtFloor},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			level, err := LoadLevel(strings.NewReader(tc.level))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := level.Map[level.Player.Y][level.Player.X].Symbol; got != tc.want {
+				t.Errorf("expected player to stand on %q, got %q", tc.want, got)
+			}
+			if got := level.bfsFloor(level.Player.Pos).Symbol; got != tc.want {
+				t.Errorf("expected bfs to find %q, got %q", tc.want, got)
+			}
+		})
+	}
 }
 
 func TestAstar(t *testing.T) {
+	level, err := LoadLevel(strings.NewReader("#######\n#@..#.#\n#.#.#.#\n#.#...#\n#######\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := level.astar(Pos{1, 1}, Pos{5, 1})
+	if len(path) != 9 || path[0] != (Pos{1, 1}) || path[len(path)-1] != (Pos{5, 1}) {
+		t.Fatalf("expected a 9 step path round the walls, got %v", path)
+	}
+	for i := 1; i < len(path); i++ {
+		dx, dy := path[i].X-path[i-1].X, path[i].Y-path[i-1].Y
+		if !canWalk(level, path[i]) || dx*dx+dy*dy != 1 {
+			t.Errorf("expected path to take single walkable steps, got %v", path)
+		}
+	}
+	if path := level.astar(Pos{1, 1}, Pos{0, 0}); path != nil {
+		t.Errorf("expected no path into a wall, got %v", path)
+	}
 
+	// a goal walled off from a big open map gives up instead of searching everywhere
+	rows := make([]string, 100)
+	for y := range rows {
+		rows[y] = strings.Repeat(".", 98) + "#."
+	}
+	rows[0] = "@" + rows[0][1:]
+	big, err := LoadLevel(strings.NewReader(strings.Join(rows, "\n") + "\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path := big.astar(Pos{0, 0}, Pos{99, 50}); path != nil {
+		t.Errorf("expected no path past the wall, got %d steps", len(path))
+	}
+	if path := big.astar(Pos{0, 0}, Pos{8, 8}); len(path) != 17 {
+		t.Errorf("expected nearby goals to still be found, got %v", path)
+	}
 }
 
 func TestRun(t *testing.T) {
+	g, err := NewGame("maps/cellar.map", 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	views := g.Subscribe()
+	done := make(chan struct{})
+	go func() {
+		g.Run(context.Background())
+		close(done)
+	}()
+	if view := <-views; view.Player.Pos != (Pos{1, 1}) {
+		t.Fatalf("expected the first view before any input, got player at %v", view.Player.Pos)
+	}
+	g.InputChan <- &Input{Typ: Right}
+	if view := <-views; view.Player.Pos != (Pos{2, 1}) {
+		t.Errorf("expected a view after the move, got player at %v", view.Player.Pos)
+	}
+	g.InputChan <- &Input{Typ: CloseWindow, Views: views}
+	<-done
+	if _, ok := <-views; ok {
+		t.Error("expected closing the last window to stop the game")
+	}
+}
+
+func TestRunCost(t *testing.T) {
+	tests := []struct {
+		name  string
+		level string
+		want  float64 // time the run takes, in steps across dirt
+	}{
+		{"step", "#####\n#@..#\n#####\n", runCost},
+		{"attack", "#####\n#@R.#\n#####\n", 1},
+		{"wall", "###\n#@#\n###\n", 1},
+		{"door", "#####\n#@|.#\n#####\n", 1},
+	}
+	for _, test := range tests {
+		level, err := LoadLevel(strings.NewReader(test.level))
+		if err != nil {
+			t.Fatal(err)
+		}
+		d := NewLevelDriver(level)
+		player := d.Player()
+		d.Step(Input{Typ: Right, Run: true})
+		want := test.want * ActionCost / player.ActionSpeed()
+		if math.Abs(player.NextAction-want) > 1e-9 {
+			t.Errorf("%s: expected running to take %f, took %f", test.name, want, player.NextAction)
+		}
+	}
+}
+
+func TestWorldStairs(t *testing.T) {
+	world, err := NewWorld(1, map[int]string{0: "maps/cellar.map"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	surface := world.CurrentLevel()
+	player := surface.Player
+	delete(surface.Monsters, Pos{3, 3})
+	surface.Map[1][1].Seen = true
+
+	if err := world.ChangeDepth(-1); err == nil {
+		t.Error("expected error going above the surface")
+	}
+
+	player.Pos = Pos{18, 6}
+	if err := world.ChangeDepth(1); err != nil {
+		t.Fatal(err)
+	}
+	below := world.CurrentLevel()
+	if world.Depth != 1 || below == surface || below.Depth != 1 {
+		t.Fatalf("expected to be on a new level at depth 1, got depth %d", world.Depth)
+	}
+	if below.Player != player {
+		t.Error("expected the same player to move between levels")
+	}
+	if below.Map[player.Y][player.X].Symbol != UpStairs {
+		t.Errorf("expected to arrive on up stairs, got %q", below.Map[player.Y][player.X].Symbol)
+	}
+
+	if err := world.ChangeDepth(-1); err != nil {
+		t.Fatal(err)
+	}
+	if world.CurrentLevel() != surface {
+		t.Fatal("expected to return to the surface level")
+	}
+	if player.Pos != (Pos{18, 6}) {
+		t.Errorf("expected to arrive on the down stairs, got %v", player.Pos)
+	}
+	if _, exists := surface.Monsters[Pos{3, 3}]; exists {
+		t.Error("expected killed monster to stay dead")
+	}
+	if !surface.Map[1][1].Seen {
+		t.Error("expected fog of war to be preserved")
+	}
+
+	if err := world.ChangeDepth(1); err != nil {
+		t.Fatal(err)
+	}
+	if world.CurrentLevel() != below {
+		t.Error("expected revisited level to be reused")
+	}
+}
+
+func TestWorldNoStairs(t *testing.T) {
+	surface, err := LoadLevel(strings.NewReader("version 1\nmap\n#####\n#@..#\n#####\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	below, err := LoadLevel(strings.NewReader("version 1\nmap\n#####\n#...#\n#..@#\n#####\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	world := &World{Levels: map[int]*Level{0: surface, 1: below}}
+	player := surface.Player
+
+	// without stairs to arrive on the player turns up where each level starts
+	if err := world.ChangeDepth(1); err != nil {
+		t.Fatal(err)
+	}
+	if player.Pos != (Pos{3, 2}) {
+		t.Errorf("expected to arrive at the start of depth 1, got %v", player.Pos)
+	}
+	if err := world.ChangeDepth(-1); err != nil {
+		t.Fatal(err)
+	}
+	if player.Pos != (Pos{1, 1}) {
+		t.Errorf("expected to arrive back at the start of the surface, got %v", player.Pos)
+	}
+}
+
+func TestWorldOccupiedStairs(t *testing.T) {
+	rat, _ := bestiary.Def("rat")
+	tests := []struct {
+		name  string
+		below string
+		rats  []Pos
+		want  Pos // where the player arrives, the surface start if they can't go down
+	}{
+		{"free stairs", "######\n#<..@#\n######\n", nil, Pos{1, 1}},
+		{"rat on the stairs", "######\n#<..@#\n######\n", []Pos{{1, 1}}, Pos{2, 1}},
+		{"nowhere to stand", "####\n#<@#\n####\n", []Pos{{1, 1}, {2, 1}}, Pos{1, 1}},
+	}
+	for _, test := range tests {
+		surface, err := LoadLevel(strings.NewReader("version 1\nmap\n#####\n#@..#\n#####\n"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		below, err := LoadLevel(strings.NewReader("version 1\nmap\n" + test.below))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, pos := range test.rats {
+			below.Monsters[pos] = rat.NewMonster(pos)
+		}
+		world := &World{Levels: map[int]*Level{0: surface, 1: below}}
+		err = world.ChangeDepth(1)
+		if player := surface.Player; player.Pos != test.want {
+			t.Errorf("%s: expected the player at %v, got %v", test.name, test.want, player.Pos)
+		}
+		if stuck := world.Depth == 0; stuck != (err != nil) {
+			t.Errorf("%s: depth %d with error %v", test.name, world.Depth, err)
+		}
+		for _, pos := range test.rats {
+			if below.Monsters[pos] == nil {
+				t.Errorf("%s: expected the rat at %v to stay put", test.name, pos)
+			}
+		}
+	}
+}
+
+func TestSaveLoad(t *testing.T) {
+	g, err := NewGame("maps/cellar.map", 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	player := g.Level.Player
+	player.Pos = Pos{18, 6}
+	g.handleInput(&Input{Typ: Descend})
+	player.Hitpoints = 321
+	player.CurrentBreath = 4
+	player.NextAction = 0.5
+	g.Level.AddEvent("something happened")
+	g.World.Levels[0].Map[1][2].Seen = true
+	g.World.Levels[0].Map[1][3].Seen = false
+	g.World.Levels[0].Monsters[Pos{3, 3}].Hitpoints = 7
+
+	var buf bytes.Buffer
+	if err := g.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := Load(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if loaded.World.Depth != 1 || loaded.Level != loaded.World.Levels[1] {
+		t.Fatalf("expected to resume on depth 1, got %d", loaded.World.Depth)
+	}
+	p := loaded.Level.Player
+	if p.Pos != player.Pos || p.Hitpoints != 321 || p.CurrentBreath != 4 || p.NextAction != 0.5 || p.Name != "Riley" {
+		t.Errorf("player not restored, got %+v", p.Character)
+	}
+	if loaded.World.Levels[0].Player != p {
+		t.Error("expected every level to share the player")
+	}
+	surface := loaded.World.Levels[0]
+	if !surface.Map[1][2].Seen || surface.Map[1][3].Seen {
+		t.Error("seen flags not restored")
+	}
+	if surface.Info.Name != "The Cellar" {
+		t.Errorf("level info not restored, got %+v", surface.Info)
+	}
+	if surface.Start != g.World.Levels[0].Start {
+		t.Errorf("start not restored, got %v", surface.Start)
+	}
+	if rat := surface.Monsters[Pos{3, 3}]; rat == nil || rat.Hitpoints != 7 {
+		t.Errorf("monster not restored, got %v", rat)
+	}
+	if len(surface.Items[Pos{14, 1}]) != 1 {
+		t.Error("items not restored")
+	}
+	for y, row := range g.Level.Map {
+		for x, tile := range row {
+			if loaded.Level.Map[y][x].Symbol != tile.Symbol {
+				t.Fatalf("tile %d,%d not restored", x, y)
+			}
+		}
+	}
+	found := false
+	for _, event := range loaded.Level.Events {
+		found = found || event == "something happened"
+	}
+	if !found {
+		t.Error("events not restored")
+	}
+
+	if _, err := Load(strings.NewReader(`{"Version": 99}`)); err != ErrSaveVersion {
+		t.Errorf("expected ErrSaveVersion, got %v", err)
+	}
+	if _, err := Load(strings.NewReader(`{"Version": 1, "Unknown": true}`)); err == nil {
+		t.Error("expected error for save with no levels")
+	}
+}
+
+func TestSavePileOrder(t *testing.T) {
+	g, err := NewGame("maps/cellar.map", 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pos := Pos{2, 1}
+	kinds := []string{"dagger", "potion", "leather-armour", "torch"}
+	for _, kind := range kinds {
+		def, _ := itemCatalog.Def(kind)
+		g.Level.Items[pos] = append(g.Level.Items[pos], def.NewItem(pos))
+	}
+
+	var buf bytes.Buffer
+	if err := g.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := Load(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pile := loaded.Level.Items[pos]
+	if len(pile) != len(kinds) {
+		t.Fatalf("expected %d items in the pile, got %d", len(kinds), len(pile))
+	}
+	for i, item := range pile {
+		if item.Kind != kinds[i] {
+			t.Errorf("expected %s at %d in the pile, got %s", kinds[i], i, item.Kind)
+		}
+	}
+}
+
+func TestLoadBestiary(t *testing.T) {
+	b, err := LoadBestiaryFile("data/bestiary.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rat, exists := b.Def("rat")
+	if !exists || rat.Symbol() != 'R' || rat.Hitpoints != 50 || rat.Speed != 2.0 {
+		t.Errorf("unexpected rat %+v", rat)
+	}
+	if spawns := b.SpawnsAt(10); len(spawns) != 1 || spawns[0].Kind != "spider" {
+		t.Errorf("expected only spiders at depth 10, got %v", spawns)
+	}
+
+	tests := []struct {
+		name, bestiary string
+	}{
+		{"not json", `monsters`},
+		{"no kind", `{"monsters": [{"name": "Bat", "glyph": "B", "hitpoints": 1, "speed": 1}]}`},
+		{"long glyph", `{"monsters": [{"kind": "bat", "name": "Bat", "glyph": "BB", "hitpoints": 1, "speed": 1}]}`},
+		{"tile glyph", `{"monsters": [{"kind": "bat", "name": "Bat", "glyph": "#", "hitpoints": 1, "speed": 1}]}`},
+		{"no speed", `{"monsters": [{"kind": "bat", "name": "Bat", "glyph": "B", "hitpoints": 1}]}`},
+		{"bad loot", `{"monsters": [{"kind": "bat", "name": "Bat", "glyph": "B", "hitpoints": 1, "speed": 1, "loot": [{"item": "wing", "chance": 1}]}]}`},
+		{"shared glyph", `{"monsters": [
+			{"kind": "bat", "name": "Bat", "glyph": "B", "hitpoints": 1, "speed": 1},
+			{"kind": "bee", "name": "Bee", "glyph": "B", "hitpoints": 1, "speed": 1}]}`},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := LoadBestiary(strings.NewReader(tc.bestiary)); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+
+	// new monsters can be placed in maps without code changes
+	custom, err := LoadBestiary(strings.NewReader(`{"monsters": [
+		{"kind": "bat", "name": "Bat", "glyph": "B", "hitpoints": 7, "speed": 3, "loot": [{"item": "rat-tail", "chance": 1}]}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	SetBestiary(custom)
+	defer SetBestiary(b)
+	level, err := LoadLevel(strings.NewReader("####\n#@B#\n####\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	bat := level.Monsters[Pos{2, 1}]
+	if bat == nil || bat.Name != "Bat" || bat.Hitpoints != 7 || bat.Kind != "bat" {
+		t.Fatalf("expected a bat, got %v", bat)
+	}
+	level.killMonster(bat)
+	if items := level.Items[Pos{2, 1}]; len(items) != 1 || items[0].Kind != "rat-tail" {
+		t.Errorf("expected bat to drop a rat tail, got %v", items)
+	}
+}
+
+func TestInventory(t *testing.T) {
+	level, err := LoadLevel(strings.NewReader("version 1\nlegend\n! item potion dirt\nmap\n#####\n#@!.#\n#####\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	player := level.Player
+
+	level.pickUp()
+	if len(player.Inventory) != 0 {
+		t.Error("expected nothing to pick up")
+	}
+	player.Move(Pos{2, 1}, level)
+	level.pickUp()
+	if len(player.Inventory) != 1 || len(level.Items) != 0 {
+		t.Fatalf("expected potion to move into the inventory, got %v", player.Inventory)
+	}
+
+	level.drop(0)
+	if len(player.Inventory) != 0 || len(level.Items[Pos{2, 1}]) != 1 {
+		t.Fatal("expected potion to be dropped")
+	}
+	level.pickUp()
+
+	player.Hitpoints = 900
+	level.use(0)
+	if player.Hitpoints != player.MaxHitpoints || len(player.Inventory) != 0 {
+		t.Errorf("expected potion to heal up to max and be used up, got %d hitpoints", player.Hitpoints)
+	}
+
+	def, _ := itemCatalog.Def("leather-armour")
+	for i := 0; i < MaxInventorySlots; i++ {
+		level.Items[player.Pos] = append(level.Items[player.Pos], def.NewItem(player.Pos))
+		level.pickUp()
+	}
+	if player.InventoryWeight() > MaxInventoryWeight {
+		t.Errorf("expected weight limit to hold, carrying %d", player.InventoryWeight())
+	}
+	if len(player.Inventory) != MaxInventoryWeight/def.Weight {
+		t.Errorf("expected to stop picking up at the weight limit, have %d items", len(player.Inventory))
+	}
+	level.use(0)
+	if len(player.Inventory) != MaxInventoryWeight/def.Weight {
+		t.Error("expected unusable item to stay in the inventory")
+	}
+}
+
+func TestFailedActions(t *testing.T) {
+	const cave = "version 1\nlegend\n! item potion dirt\nmap\n#######\n#@!..R#\n#######\n"
+	for _, typ := range []InputType{PickUp, Drop, Use, Equip, Unequip, Descend, Ascend} {
+		level, err := LoadLevel(strings.NewReader(cave))
+		if err != nil {
+			t.Fatal(err)
+		}
+		d := NewLevelDriver(level)
+		d.Press(typ)
+		if level.Time != 0 || level.Player.NextAction != 0 || level.Monsters[Pos{5, 1}] == nil {
+			t.Errorf("input %d: expected a failed action to take no time, now %f", typ, level.Time)
+		}
+	}
+
+	// picking something up does take time
+	level, err := LoadLevel(strings.NewReader(cave))
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := NewLevelDriver(level)
+	d.Press(Right)
+	before := level.Player.NextAction
+	d.Press(PickUp)
+	if level.Player.NextAction <= before {
+		t.Errorf("expected picking up to take time, next action still %f", level.Player.NextAction)
+	}
+}
+
+func TestEquipment(t *testing.T) {
+	level, err := LoadLevel(strings.NewReader("####\n#@R#\n####\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	player := level.Player
+	rat := level.Monsters[Pos{2, 1}]
+	give := func(kind string) {
+		def, _ := itemCatalog.Def(kind)
+		player.Inventory = append(player.Inventory, def.NewItem(player.Pos))
+	}
+
+	give("dagger")
+	give("sword")
+	give("leather-armour")
+	give("ring-of-might")
+	give("ring-of-might")
+	give("potion")
+
+	level.equip(5)
+	if len(player.Inventory) != 6 {
+		t.Fatal("expected potion not to be equippable")
+	}
+	level.equip(0)
+	if player.Equipment[SlotWeapon] == nil || player.AttackPower() != player.Strength+5 {
+		t.Fatalf("expected dagger to add 5 attack, got %d", player.AttackPower())
+	}
+	if player.ActionSpeed() != player.Speed+0.1 {
+		t.Errorf("expected dagger to add speed, got %f", player.ActionSpeed())
+	}
+	level.equip(0)
+	if player.Equipment[SlotWeapon].Kind != "sword" || player.Inventory[len(player.Inventory)-1].Kind != "dagger" {
+		t.Fatal("expected sword to replace dagger and dagger to go back in the pack")
+	}
+	level.equip(0)
+	level.equip(0)
+	level.equip(0)
+	if player.Equipment[SlotLeftRing] == nil || player.Equipment[SlotRightRing] == nil {
+		t.Fatal("expected two rings to fill both ring slots")
+	}
+	if player.AttackPower() != player.Strength+15+8 || player.Defense() != 4 {
+		t.Errorf("unexpected derived stats attack %d defense %d", player.AttackPower(), player.Defense())
+	}
+
+	// the sword's dice replace unarmed damage and armour soaks up hits
+	if player.DamageDice() != (Dice{2, 6, 0}) {
+		t.Errorf("expected sword damage dice, got %v", player.DamageDice())
+	}
+	rat.Accuracy = 100
+	for i := 0; i < 20; i++ {
+		result := resolveAttack(&rat.Character, &player.Character, level.RNG)
+		if result.Hit && result.Absorbed != 4 {
+			t.Fatalf("expected armour to absorb 4, got %+v", result)
+		}
+	}
+
+	level.unequip(SlotBody)
+	if player.Equipment[SlotBody] != nil || player.Defense() != 0 {
+		t.Error("expected armour to be taken off")
+	}
+	level.unequip(SlotBody)
+}
+
+func TestExperience(t *testing.T) {
+	level, err := LoadLevel(strings.NewReader("####\n#@R#\n####\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	player := level.Player
+	rat := level.Monsters[Pos{2, 1}]
+	if player.ExperienceLevel != 1 || player.Experience != 0 {
+		t.Fatalf("expected a fresh player at level 1, got %d (%d xp)", player.ExperienceLevel, player.Experience)
+	}
+
+	rat.Hitpoints = 1
+	rat.Evasion = -100
+	for len(level.Monsters) > 0 {
+		level.resolveMovement(rat.Pos)
+	}
+	if player.Experience != rat.experience() || rat.experience() == 0 {
+		t.Errorf("expected %d xp for the rat, got %d", rat.experience(), player.Experience)
+	}
+
+	hitpoints, maxHitpoints, strength, breath, sight := player.Hitpoints, player.MaxHitpoints, player.Strength, player.MaxBreath, player.SightRange
+	level.GainExperience(ExperienceForLevel(3) - player.Experience)
+	if player.ExperienceLevel != 3 {
+		t.Fatalf("expected to reach level 3, got %d", player.ExperienceLevel)
+	}
+	if player.MaxHitpoints != maxHitpoints+2*hitpointsPerLevel || player.Hitpoints != hitpoints+2*hitpointsPerLevel {
+		t.Errorf("expected hitpoints to grow, got %d/%d", player.Hitpoints, player.MaxHitpoints)
+	}
+	if player.Strength != strength+2*strengthPerLevel || player.MaxBreath != breath+2*breathPerLevel || player.SightRange != sight+1 {
+		t.Errorf("expected stats to grow, got %+v", player.Character)
+	}
+	if level.Events[level.EventPos-1] != "Riley reaches level 3" {
+		t.Errorf("expected level up event, got %q", level.Events[level.EventPos-1])
+	}
+}
+
+func TestEffects(t *testing.T) {
+	level, err := LoadLevel(strings.NewReader("#####\n#@.S#\n#####\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	player := level.Player
+
+	// stacking rules
+	player.AddEffect(Effect{Poison, 3, 1})
+	player.AddEffect(Effect{Poison, 2, 2})
+	player.AddEffect(Effect{Bleeding, 2, 1})
+	player.AddEffect(Effect{Bleeding, 3, 1})
+	player.AddEffect(Effect{Haste, 5, 1})
+	player.AddEffect(Effect{Haste, 2, 1})
+	expected := []Effect{{Poison, 3, 3}, {Bleeding, 5, 1}, {Haste, 5, 1}}
+	if len(player.Effects) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, player.Effects)
+	}
+	for i, e := range expected {
+		if player.Effects[i] != e {
+			t.Errorf("expected %v, got %v", e, player.Effects[i])
+		}
+	}
+	if player.ActionSpeed() != player.Speed*1.5 {
+		t.Errorf("expected haste to speed the player up, got %f", player.ActionSpeed())
+	}
+
+	// effects hurt each turn and wear off
+	hitpoints := player.Hitpoints
+	for i := 0; i < 3; i++ {
+		level.tickEffects(&player.Character)
+	}
+	if player.Hitpoints != hitpoints-3*(3*2+3) || player.HasEffect(Poison) || !player.HasEffect(Bleeding) {
+		t.Errorf("unexpected state after poison wore off: %d hitpoints, %v", player.Hitpoints, player.Effects)
+	}
+	player.RemoveEffect(Bleeding)
+	player.RemoveEffect(Haste)
+
+	player.Hitpoints = 1
+	player.AddEffect(Effect{Poison, 5, 1})
+	if cause := level.tickEffects(&player.Character); cause != "died of poison" {
+		t.Errorf("expected poison to kill, got %q", cause)
+	}
+
+	// regenerating after a lethal tick doesn't bring anyone back
+	player.Hitpoints = 1
+	player.Effects = nil
+	player.AddEffect(Effect{Poison, 5, 1})
+	player.AddEffect(Effect{Regeneration, 5, 1})
+	if cause := level.tickEffects(&player.Character); cause != "died of poison" || player.Hitpoints > 0 {
+		t.Errorf("expected poison to kill before regeneration, got %q with %d hitpoints", cause, player.Hitpoints)
+	}
+
+	// effects without healing leave boosted hitpoints alone
+	player.Hitpoints = player.MaxHitpoints + 10
+	player.Effects = nil
+	player.AddEffect(Effect{Haste, 5, 1})
+	level.tickEffects(&player.Character)
+	if player.Hitpoints != player.MaxHitpoints+10 {
+		t.Errorf("expected haste not to touch hitpoints, got %d of %d", player.Hitpoints, player.MaxHitpoints)
+	}
+	player.Hitpoints = player.MaxHitpoints
+	player.Effects = nil
+
+	// spiders poison whoever they hit
+	spider := level.Monsters[Pos{3, 1}]
+	if spider.OnHit == nil || spider.OnHit.Kind != Poison {
+		t.Fatalf("expected spiders to poison on hit, got %v", spider.OnHit)
+	}
+	spider.OnHitChance = 0
+	spider.Accuracy = 100
+	player.Evasion = -100
+	player.Move(Pos{2, 1}, level)
+	spider.Move(player.Pos, level)
+	if !player.HasEffect(Poison) {
+		t.Error("expected spider bite to poison the player")
+	}
+
+	// potions can apply effects
+	def, _ := itemCatalog.Def("haste-potion")
+	player.Inventory = append(player.Inventory, def.NewItem(player.Pos))
+	level.use(0)
+	if !player.HasEffect(Haste) || len(player.Inventory) != 0 {
+		t.Error("expected potion of haste to haste the player")
+	}
+}
+
+func TestScheduler(t *testing.T) {
+	level, err := LoadLevel(strings.NewReader("########\n#@....R#\n########\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := newGame(&World{Levels: map[int]*Level{0: level}, LevelPaths: map[int]string{}})
+	rat := g.Level.Monsters[Pos{6, 1}]
+	rat.Accuracy = -100
+
+	// rats are twice as fast as the player, so they take two single steps per turn
+	positions := []Pos{{4, 1}, {2, 1}}
+	for _, expected := range positions {
+		g.handleInput(&Input{Typ: Left})
+		if rat.Pos != expected {
+			t.Fatalf("expected rat at %v, got %v", expected, rat.Pos)
+		}
+	}
+	eventPos := g.Level.EventPos
+	g.handleInput(&Input{Typ: Left})
+	if g.Level.EventPos-eventPos != 2 {
+		t.Errorf("expected rat to attack twice, got %v", g.Level.Events)
+	}
+	if g.Level.Time != 3 || g.Level.Player.NextAction != 3 {
+		t.Errorf("expected three turns to have passed, got %f", g.Level.Time)
+	}
+
+	// slowed to half speed the rat only gets one action per turn
+	rat.AddEffect(Effect{Slow, 10, 1})
+	eventPos = g.Level.EventPos
+	g.handleInput(&Input{Typ: Left})
+	if g.Level.EventPos-eventPos != 1 {
+		t.Errorf("expected slowed rat to attack once, got %v", g.Level.Events)
+	}
+}
+
+func TestMonsterAI(t *testing.T) {
+	level, err := LoadLevel(strings.NewReader("#########\n#@..R..S#\n####.####\n#.......#\n#########\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	player := level.Player
+	rat := level.Monsters[Pos{4, 1}]
+	spider := level.Monsters[Pos{7, 1}]
+	if rat.State != Wandering || spider.State != Asleep {
+		t.Fatalf("expected rats to start wandering and spiders asleep, got %v and %v", rat.State, spider.State)
+	}
+
+	// spiders sleep through a player in view but out of reach
+	spider.Update(level)
+	if spider.State != Asleep || spider.Pos != (Pos{7, 1}) {
+		t.Errorf("expected spider to stay asleep, got %v at %v", spider.State, spider.Pos)
+	}
+	spider.Hitpoints--
+	spider.think(level)
+	if spider.State != Hunting || level.Debug[spider.Pos] != "Spider: asleep -> hunting" {
+		t.Errorf("expected hurt spider to wake up, got %v %q", spider.State, level.Debug[spider.Pos])
+	}
+
+	rat.think(level)
+	if rat.State != Hunting || rat.LastSeen != player.Pos {
+		t.Fatalf("expected rat to hunt the player, got %v", rat.State)
+	}
+	player.Pos = Pos{1, 3}
+	rat.think(level)
+	if rat.State != Searching || rat.LastSeen != (Pos{1, 1}) {
+		t.Fatalf("expected rat to search where the player was last seen, got %v at %v", rat.State, rat.LastSeen)
+	}
+	rat.Update(level)
+	if rat.Pos != (Pos{3, 1}) {
+		t.Errorf("expected searching rat to head for the last known position, got %v", rat.Pos)
+	}
+	rat.SearchTurns = 0
+	rat.think(level)
+	if rat.State != Wandering {
+		t.Errorf("expected rat to give up searching, got %v", rat.State)
+	}
+
+	player.Pos = Pos{2, 1}
+	rat.Hitpoints = 5
+	rat.Update(level)
+	if rat.State != Fleeing || rat.Pos != (Pos{4, 1}) {
+		t.Errorf("expected badly hurt rat to run away, got %v at %v", rat.State, rat.Pos)
+	}
+}
+
+func TestNoise(t *testing.T) {
+	level, err := LoadLevel(strings.NewReader("##########\n#@|....S.#\n##########\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	spider := level.Monsters[Pos{7, 1}]
+
+	// monster fov doesn't touch what the player can see
+	fov := level.FieldOfView(&spider.Character)
+	if !fov[Pos{5, 1}] || fov[Pos{1, 1}] {
+		t.Errorf("expected spider to see the corridor but not through the door")
+	}
+	if level.Map[1][5].Visible {
+		t.Error("expected monster fov not to mark tiles visible")
+	}
+
+	level.resolveMovement(Pos{2, 1})
+	if spider.State != Searching || spider.LastSeen != (Pos{2, 1}) {
+		t.Errorf("expected door to wake the spider, got %v heading to %v", spider.State, spider.LastSeen)
+	}
+
+	// stealthy players are noticed from closer
+	level, err = LoadLevel(strings.NewReader("############\n#@........R#\n############\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rat := level.Monsters[Pos{10, 1}]
+	if rat.seesPlayer(level) {
+		t.Error("expected stealthy player to go unnoticed")
+	}
+	level.Player.Stealth = 0
+	if !rat.seesPlayer(level) {
+		t.Error("expected player without stealth to be seen")
+	}
+}
+
+func TestDijkstraMap(t *testing.T) {
+	level, err := LoadLevel(strings.NewReader("version 1\nlegend\n! item potion dirt\nmap\n#######\n#@...>#\n#.###.#\n#..!..#\n#######\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		d    *DijkstraMap
+		pos  Pos
+		dist int
+	}{
+		{"player", level.PlayerMap(), Pos{1, 1}, 0},
+		{"player corridor", level.PlayerMap(), Pos{5, 3}, 60},
+		{"player wall", level.PlayerMap(), Pos{2, 2}, Unreachable},
+		{"items", level.ItemMap(), Pos{1, 1}, 40},
+		{"exits", level.ExitMap(), Pos{3, 3}, 40},
+	}
+	for _, test := range tests {
+		if dist := test.d.At(test.pos); dist != test.dist {
+			t.Errorf("%s: expected %d at %v, got %d", test.name, test.dist, test.pos, dist)
+		}
+	}
+
+	// rolling downhill on the player map leads to the player, on the flee map away
+	if next, ok := level.PlayerMap().Downhill(level, Pos{3, 3}); !ok || next != (Pos{2, 3}) {
+		t.Errorf("expected to step towards the player, got %v", next)
+	}
+	if next, ok := level.FleeMap().Downhill(level, Pos{2, 1}); !ok || next != (Pos{3, 1}) {
+		t.Errorf("expected to step away from the player, got %v", next)
+	}
+	if level.PlayerMap() != level.PlayerMap() {
+		t.Error("expected player map to be shared until the player moves")
+	}
+}
+
+// benchmarkLevel - a generated level with a spawn of monsters to path from
+func benchmarkLevel(b *testing.B) *Level {
+	level, err := GenerateLevel(100, 100, 7, 0)
+	if err != nil {
+		b.Fatal(err)
+	}
+	return level
+}
+
+// BenchmarkAstarPerMonster - a full search from every monster, without the node limit astar gives monsters
+// so it compares fairly with one Dijkstra map covering the whole level
+func BenchmarkAstarPerMonster(b *testing.B) {
+	level := benchmarkLevel(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, m := range level.Monsters {
+			level.astarWithin(m.Pos, level.Player.Pos, math.MaxInt32)
+		}
+	}
+}
+
+func BenchmarkDijkstraMap(b *testing.B) {
+	level := benchmarkLevel(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		level.flow = nil
+		d := level.PlayerMap()
+		for _, m := range level.Monsters {
+			d.Downhill(level, m.Pos)
+		}
+	}
+}
+
+func TestTerrainCosts(t *testing.T) {
+	level, err := LoadLevel(strings.NewReader("#######\n#@~~~.#\n#.....#\n#$_...#\n#######\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// paths go the long way round rather than through the water
+	path := level.astar(Pos{1, 1}, Pos{5, 1})
+	for _, pos := range path {
+		if level.Map[pos.Y][pos.X].Symbol == Water {
+			t.Fatalf("expected path to avoid water, got %v", path)
+		}
+	}
+	if len(path) != 7 {
+		t.Errorf("expected the six step path round the lake, got %v", path)
+	}
+	if level.PlayerMap().At(Pos{5, 1}) != 60 {
+		t.Errorf("expected flow field to go round the lake, got %d", level.PlayerMap().At(Pos{5, 1}))
+	}
+
+	g := newGame(&World{Levels: map[int]*Level{0: level}, LevelPaths: map[int]string{}})
+	player := level.Player
+	steps := []struct {
+		input InputType
+		time  float64
+	}{
+		{Down, 1.0},
+		{Down, 2.5},
+		{Right, 3.2},
+		{Up, 4.2},
+		{Up, 7.2},
+	}
+	for _, step := range steps {
+		g.handleInput(&Input{Typ: step.input})
+		if math.Abs(player.NextAction-step.time) > 1e-9 {
+			t.Errorf("expected player's next action at %.1f after moving to %v, got %f", step.time, player.Pos, player.NextAction)
+		}
+	}
+}
+
+func TestEightWayMovement(t *testing.T) {
+	level, err := LoadLevel(strings.NewReader("######\n#@...#\n#.#..#\n#|...#\n######\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name         string
+		connectivity Connectivity
+		from, to     Pos
+		ok           bool
+	}{
+		{"orthogonal", FourWay, Pos{1, 1}, Pos{2, 1}, true},
+		{"diagonal on four way", FourWay, Pos{3, 1}, Pos{4, 2}, false},
+		{"diagonal", EightWay, Pos{3, 1}, Pos{4, 2}, true},
+		{"cutting a wall corner", EightWay, Pos{3, 2}, Pos{2, 1}, false},
+		{"squeezing past a door", EightWay, Pos{2, 3}, Pos{1, 2}, false},
+		{"too far", EightWay, Pos{1, 1}, Pos{3, 1}, false},
+	}
+	for _, test := range tests {
+		level.Connectivity = test.connectivity
+		if ok := level.canStep(test.from, test.to); ok != test.ok {
+			t.Errorf("%s: expected %v, got %v", test.name, test.ok, ok)
+		}
+	}
+
+	g := newGame(&World{Levels: map[int]*Level{0: level}, LevelPaths: map[int]string{}})
+	player := level.Player
+	g.World.SetConnectivity(FourWay)
+	player.Move(Pos{3, 1}, level)
+	g.handleInput(&Input{Typ: DownRight})
+	if player.Pos != (Pos{3, 1}) || player.NextAction != 0 {
+		t.Errorf("expected diagonal input to do nothing on a four way game, got %v", player.Pos)
+	}
+
+	g.World.SetConnectivity(EightWay)
+	g.handleInput(&Input{Typ: DownRight})
+	if player.Pos != (Pos{4, 2}) {
+		t.Errorf("expected to move diagonally, got %v", player.Pos)
+	}
+	if path := level.astar(Pos{1, 1}, Pos{4, 3}); len(path) != 5 {
+		t.Errorf("expected diagonal steps to shorten the path, got %v", path)
+	}
+	pos := Pos{3, 1}
+	if !pos.IsNextToPlayer(level) {
+		t.Error("expected diagonal neighbours to count as next to the player")
+	}
+}
+
+func TestShadowcastFOV(t *testing.T) {
+	pillar, err := LoadLevel(strings.NewReader("##########\n#........#\n#........#\n#.@.#....#\n#........#\n#........#\n##########\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	corridor, err := LoadLevel(strings.NewReader("##########\n#@.......#\n########.#\n########.#\n##########\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		level   *Level
+		pos     Pos
+		visible bool
+	}{
+		{"pillar", pillar, Pos{4, 3}, true},
+		{"behind pillar", pillar, Pos{5, 3}, false},
+		{"far behind pillar", pillar, Pos{8, 3}, false},
+		{"beside pillar", pillar, Pos{5, 2}, true},
+		{"room corner", pillar, Pos{8, 1}, true},
+		{"corridor end", corridor, Pos{8, 1}, true},
+		{"corridor wall", corridor, Pos{5, 2}, true},
+		{"round the corner", corridor, Pos{8, 3}, false},
+	}
+	for _, test := range tests {
+		visible := ShadowcastFOV{}.Compute(test.level, test.level.Player.Pos, 20)
+		if visible[test.pos] != test.visible {
+			t.Errorf("%s: expected visible %v at %v", test.name, test.visible, test.pos)
+		}
+	}
+
+	// anything the player can see can see the player back
+	level, err := LoadLevel(strings.NewReader("############\n#..#.......#\n#.....#.#..#\n#.#.......##\n#....@..#..#\n##.#...#...#\n#.....#....#\n############\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := make(map[Pos]map[Pos]bool)
+	for y, row := range level.Map {
+		for x := range row {
+			if canSeeThrough(level, Pos{x, y}) {
+				seen[Pos{x, y}] = ShadowcastFOV{}.Compute(level, Pos{x, y}, 20)
+			}
+		}
+	}
+	for a, fromA := range seen {
+		for b, fromB := range seen {
+			if fromA[b] != fromB[a] {
+				t.Fatalf("expected symmetric visibility between %v and %v", a, b)
+			}
+		}
+	}
+
+	// bresenham is still there for anyone who wants it
+	SetFOV(BresenhamFOV{})
+	defer SetFOV(ShadowcastFOV{})
+	if !corridor.FieldOfView(&corridor.Player.Character)[Pos{8, 1}] {
+		t.Error("expected bresenham fov to see down the corridor")
+	}
+}
+
+func BenchmarkPlayerMove(b *testing.B) {
+	algorithms := []struct {
+		name string
+		fov  FOV
+	}{
+		{"bresenham", BresenhamFOV{}},
+		{"shadowcast", ShadowcastFOV{}},
+	}
+	defer SetFOV(ShadowcastFOV{})
+	for _, algorithm := range algorithms {
+		b.Run(algorithm.name, func(b *testing.B) {
+			SetFOV(algorithm.fov)
+			level := benchmarkLevel(b)
+			start := level.Player.Pos
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				level.Player.Move(start, level)
+			}
+		})
+	}
+}
+
+func TestLight(t *testing.T) {
+	cave := "version 1\nambient 0\nmap\n######################\n#@...................#\n#..................*.#\n######################\n"
+	level, err := LoadLevel(strings.NewReader(cave))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		pos     Pos
+		visible bool
+	}{
+		{"own light", Pos{3, 1}, true},
+		{"dark", Pos{6, 1}, false},
+		{"campfire", Pos{19, 2}, true},
+		{"lit by campfire", Pos{14, 1}, true},
+	}
+	for _, test := range tests {
+		if level.Map[test.pos.Y][test.pos.X].Visible != test.visible {
+			t.Errorf("%s: expected visible %v at %v", test.name, test.visible, test.pos)
+		}
+	}
+	if light := level.Map[1][1].Light; light != 1 {
+		t.Errorf("expected the player's own tile to be fully lit, got %f", light)
+	}
+
+	// a torch lights up more of the cave
+	def, _ := itemCatalog.Def("torch")
+	level.Player.Inventory = append(level.Player.Inventory, def.NewItem(level.Player.Pos))
+	level.lineOfSight()
+	if !level.Map[1][6].Visible || level.Player.LightRadius() != def.Light {
+		t.Errorf("expected torch to light the dark, radius %d", level.Player.LightRadius())
+	}
+
+	// glowing monsters can be seen in the dark
+	beetle, _ := bestiary.Def("fire-beetle")
+	level.Monsters[Pos{8, 1}] = beetle.NewMonster(Pos{8, 1})
+	level.Player.Inventory = nil
+	level.lineOfSight()
+	if !level.Map[1][8].Visible {
+		t.Error("expected glowing monster to be visible")
+	}
+
+	// outdoor light follows the clock
+	level.Info = LevelInfo{Ambient: 1, Outdoor: true}
+	if level.Ambient() != 1 {
+		t.Errorf("expected full light at noon, got %f", level.Ambient())
+	}
+	level.Time = dayLength / 2
+	if math.Abs(level.Ambient()-nightAmbient) > 1e-9 {
+		t.Errorf("expected night at midnight, got %f", level.Ambient())
+	}
+}
+
+func TestLightSources(t *testing.T) {
+	cave := "version 1\nambient 0\nmap\n############\n#@.........#\n#..........#\n############\n"
+	level, err := LoadLevel(strings.NewReader(cave))
+	if err != nil {
+		t.Fatal(err)
+	}
+	def, _ := itemCatalog.Def("torch")
+	for _, pos := range []Pos{{9, 2}, {4, 1}, {7, 2}, {2, 2}, {10, 1}} {
+		level.Items[pos] = append(level.Items[pos], def.NewItem(pos))
+	}
+
+	// item lights come in position order, however the map iterates
+	want := []Pos{{4, 1}, {10, 1}, {2, 2}, {7, 2}, {9, 2}}
+	for i := 0; i < 20; i++ {
+		var got []Pos
+		for _, source := range level.LightSources() {
+			if source.Pos != level.Player.Pos {
+				got = append(got, source.Pos)
+			}
+		}
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Fatalf("expected light sources %v, got %v", want, got)
+		}
+	}
+
+	// monsters looking around during a turn share one light map
+	level.lineOfSight()
+	before := level.lightAt(Pos{10, 2})
+	lights := level.lights
+	delete(level.Items, Pos{10, 1})
+	delete(level.Items, Pos{9, 2})
+	if level.lightAt(Pos{10, 2}) != before || level.lights != lights {
+		t.Error("expected the light map to be reused within a turn")
+	}
+	level.Turn++
+	if level.lightAt(Pos{10, 2}) >= before || level.lights == lights {
+		t.Error("expected the light map to be worked out again next turn")
+	}
+}
+
+func TestSeededGame(t *testing.T) {
+	play := func(seed int64) []byte {
+		g, err := NewGame("", seed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		inputs := []InputType{Right, Right, Down, Down, Left, Up, Right, Down, Down, Right}
+		for i := 0; i < 5; i++ {
+			for _, typ := range inputs {
+				g.handleInput(&Input{Typ: typ})
+			}
+		}
+		var buf bytes.Buffer
+		if err := g.Save(&buf); err != nil {
+			t.Fatal(err)
+		}
+		return buf.Bytes()
+	}
+	if !bytes.Equal(play(5), play(5)) {
+		t.Error("expected the same seed and inputs to give the same game")
+	}
+	if bytes.Equal(play(5), play(6)) {
+		t.Error("expected different seeds to give different games")
+	}
+
+	// streams for different levels and purposes don't overlap
+	seeds := make(map[int64]bool)
+	for depth := 0; depth < 5; depth++ {
+		for _, stream := range []Stream{StreamWorldgen, StreamCombat, StreamAI} {
+			seeds[streamSeed(5, depth, stream)] = true
+		}
+	}
+	if len(seeds) != 15 {
+		t.Errorf("expected every stream to get its own seed, got %d distinct", len(seeds))
+	}
+}
+
+func TestReplay(t *testing.T) {
+	g, err := NewGame("maps/cellar.map", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var recording bytes.Buffer
+	if err := g.Record(&recording); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan struct{})
+	go func() {
+		g.Run(context.Background())
+		close(done)
+	}()
+	inputs := []InputType{Right, Down, Down, Right, Right, Right, Up, Left, PickUp, Right}
+	for _, typ := range inputs {
+		g.InputChan <- &Input{Typ: typ}
+	}
+	g.InputChan <- &Input{Typ: QuitGame}
+	<-done
+
+	replay, err := LoadReplay(bytes.NewReader(recording.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(replay.Inputs) != len(inputs) || replay.Hash == "" || replay.Header.Seed != 3 {
+		t.Fatalf("expected %d inputs and a hash, got %+v", len(inputs), replay)
+	}
+	played, err := replay.Play()
+	if err != nil {
+		t.Fatalf("expected replay to match, got %v", err)
+	}
+	if played.Level.Player.Pos != g.Level.Player.Pos {
+		t.Errorf("expected player to end at %v, got %v", g.Level.Player.Pos, played.Level.Player.Pos)
+	}
+
+	// stepping the driver ends up in the same state as Run
+	stepped, err := replay.NewGame()
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := &Driver{stepped}
+	for _, input := range replay.Inputs {
+		d.Step(Input{Typ: input.Typ, Slot: input.Slot, Run: input.Run})
+	}
+	if err := replay.Verify(stepped); err != nil {
+		t.Errorf("expected the driver to match Run, got %v", err)
+	}
+
+	replay.Inputs = replay.Inputs[1:]
+	if _, err := replay.Play(); err != ErrReplayMismatch {
+		t.Errorf("expected a different replay to be caught, got %v", err)
+	}
+	if _, err := LoadReplay(strings.NewReader("{\"Input\":{\"Typ\":1}}\n")); err != ErrReplayHeader {
+		t.Errorf("expected missing header error, got %v", err)
+	}
+}
+
+func TestReplayItemPiles(t *testing.T) {
+	// a row of items, picked up and dropped onto the next one to make piles of two
+	row := "#@" + strings.Repeat("pd", 14) + ".#"
+	level := fmt.Sprintf("version 1\nsize %d 3\nlegend\np item potion dirt\nd item dagger dirt\nmap\n%s\n%s\n%s\n",
+		len(row), strings.Repeat("#", len(row)), row, strings.Repeat("#", len(row)))
+	path := filepath.Join(t.TempDir(), "piles.map")
+	if err := os.WriteFile(path, []byte(level), 0644); err != nil {
+		t.Fatal(err)
+	}
+	d, err := NewDriver(path, 9)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var recording bytes.Buffer
+	if err := d.Game.Record(&recording); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 14; i++ {
+		d.Press(Right, PickUp, Right, Drop)
+	}
+	piled := 0
+	for _, pile := range d.Level().Items {
+		if len(pile) > 1 {
+			piled += len(pile)
+		}
+	}
+	if piled <= 12 {
+		t.Fatalf("expected more than 12 items in piles, got %d", piled)
+	}
+	first, err := d.Game.StateHash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 50; i++ {
+		if hash, _ := d.Game.StateHash(); hash != first {
+			t.Fatal("expected the same game to always hash the same")
+		}
+	}
+	d.Game.stopRecording()
+
+	replay, err := LoadReplay(&recording)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := replay.Play(); err != nil {
+		t.Errorf("expected replay with item piles to match, got %v", err)
+	}
+}
+
+// TestDriver - combat and drowning played out on small levels
+func TestDriver(t *testing.T) {
+	runDriverCases(t, []driverCase{
+		{"kill monster", "####\n#@R#\n####\n", repeatInput(Right, 40), func(t *testing.T, d *Driver) {
+			if len(d.Level().Monsters) != 0 || d.Player().Experience == 0 {
+				t.Errorf("expected the rat dead and experience gained, got %d monsters and %d xp", len(d.Level().Monsters), d.Player().Experience)
+			}
+			if d.Player().Pos == (Pos{1, 1}) {
+				t.Error("expected player to walk on once the rat died")
+			}
+		}},
+		{"drown", "#####\n#@~~#\n#####\n", repeatInput(Right, 20), func(t *testing.T, d *Driver) {
+			if d.State() != Dead || d.Player().CauseOfDeath != "drowned" || d.View().State != Dead {
+				t.Errorf("expected player to drown, got %v %q", d.State(), d.Player().CauseOfDeath)
+			}
+		}},
+		{"surface for air", "#####\n#@~.#\n#####\n", []InputType{Right, Right, Right}, func(t *testing.T, d *Driver) {
+			if d.State() != Playing || d.Player().HasEffect(Drowning) {
+				t.Errorf("expected to stop drowning after leaving the water, got %v", d.Player().DescribeEffects())
+			}
+		}},
+	})
+}
+
+func repeatInput(typ InputType, n int) []InputType {
+	typs := make([]InputType, n)
+	for i := range typs {
+		typs[i] = typ
+	}
+	return typs
+}
+
+func TestSimulatedGames(t *testing.T) {
+	games, turns := 10, 200
+	if testing.Short() {
+		games = 2
+	}
+	choices := []InputType{Up, Down, Left, Right, Up, Down, Left, Right, PickUp, Use, Equip, Descend, Ascend}
+	for seed := int64(1); seed <= int64(games); seed++ {
+		d, err := NewDriver("", seed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r := NewRNG(seed)
+		d.Play(turns, func(level *Level) Input {
+			return Input{Typ: choices[r.Intn(len(choices))], Run: r.Intn(4) == 0}
+		})
+
+		level := d.Level()
+		player := d.Player()
+		if !canPass(level, player.Pos) {
+			t.Errorf("seed %d: player stuck in %q at %v", seed, level.Map[player.Y][player.X].Symbol, player.Pos)
+		}
+		if player.Hitpoints > player.MaxHitpoints || (player.Hitpoints <= 0) != (d.State() == Dead) {
+			t.Errorf("seed %d: player has %d of %d hitpoints in state %v", seed, player.Hitpoints, player.MaxHitpoints, d.State())
+		}
+		for pos, m := range level.Monsters {
+			if m.Pos != pos || !canPass(level, pos) || pos == player.Pos {
+				t.Errorf("seed %d: %s at %v is filed under %v", seed, m.Name, m.Pos, pos)
+			}
+		}
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	level, err := LoadLevelFromFile("maps/cellar.map")
+	if err != nil {
+		t.Fatal(err)
+	}
+	level.AddEvent("first")
+	level.AddEvent("second")
+	view := level.Snapshot(Playing)
+	if view.Player.Pos != level.Player.Pos || view.Monsters[Pos{3, 3}] != 'R' || view.Items[Pos{14, 1}] != '!' {
+		t.Fatalf("expected view to match the level, got player %v monsters %v items %v", view.Player.Pos, view.Monsters, view.Items)
+	}
+	if last := view.Events[len(view.Events)-1]; last != "second" || len(view.Events) != len(level.Events) {
+		t.Errorf("expected newest event last, got %q", view.Events)
+	}
+
+	// changing the level afterwards mustn't show up in a view already sent
+	level.Map[1][2].Symbol = Water
+	level.Player.Pos = Pos{2, 1}
+	level.Player.Inventory = append(level.Player.Inventory, level.Items[Pos{14, 1}][0])
+	delete(level.Monsters, Pos{3, 3})
+	level.AddEvent("third")
+	if view.Map[1][2].Symbol != DirtFloor || view.Player.Pos != (Pos{1, 1}) || len(view.Player.Inventory) != 0 {
+		t.Error("expected view not to change with the level")
+	}
+	if _, exists := view.Monsters[Pos{3, 3}]; !exists || view.Events[len(view.Events)-1] != "second" {
+		t.Error("expected view to keep its monsters and events")
+	}
+}
+
+// TestBroadcastRace - uis read every view while the game carries on, run with -race
+func TestBroadcastRace(t *testing.T) {
+	g, err := NewGame("maps/cellar.map", 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	subscriptions := []<-chan *View{g.Subscribe(), g.Subscribe()}
+	done := make(chan struct{})
+	go func() {
+		g.Run(ctx)
+		close(done)
+	}()
+
+	// each window looks over its views while the game is already handling the next input
+	var wg sync.WaitGroup
+	last := make([]*View, len(subscriptions))
+	for i, views := range subscriptions {
+		wg.Add(1)
+		go func(i int, views <-chan *View) {
+			defer wg.Done()
+			for view := range views {
+				for _, row := range view.Map {
+					for _, tile := range row {
+						_ = tile.Symbol
+					}
+				}
+				for range view.Monsters {
+				}
+				last[i] = view
+			}
+		}(i, views)
+	}
+	for i := 0; i < 20; i++ {
+		g.InputChan <- &Input{Typ: []InputType{Right, Down, Left, Up}[i%4]}
+	}
+	g.InputChan <- &Input{Typ: QuitGame}
+	<-done
+	wg.Wait()
+
+	for i, view := range last {
+		if view == nil || view.Player.Pos != g.Level.Player.Pos {
+			t.Errorf("expected window %d to end up with the latest view", i)
+		}
+	}
+}
+
+func TestRunSubscribers(t *testing.T) {
+	g, err := NewGame("maps/cellar.map", 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	stalled := g.Subscribe()
+	done := make(chan struct{})
+	go func() {
+		g.Run(ctx)
+		close(done)
+	}()
+
+	// a window that never reads mustn't hold up the game, it just misses views
+	for i := 0; i < 3*inputBuffer; i++ {
+		g.InputChan <- &Input{Typ: []InputType{Right, Left}[i%2]}
+	}
+	late := g.Subscribe()
+	view := <-late
+	if view == nil || g.Subscribers() != 2 {
+		t.Fatalf("expected a late window to start with the latest view, got %v with %d subscribers", view, g.Subscribers())
+	}
+	g.Unsubscribe(late)
+	if _, ok := <-late; ok || g.Subscribers() != 1 {
+		t.Error("expected unsubscribing to close the window's channel")
+	}
+	g.InputChan <- &Input{Typ: CloseWindow, Views: late}
 
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("expected cancelling the context to stop the game")
+	}
+	// only the newest view is ever waiting, then the channel is closed
+	count := 0
+	for range stalled {
+		count++
+	}
+	if count != 1 {
+		t.Errorf("expected the stalled window to have one view waiting, got %d", count)
+	}
+	if _, ok := <-g.Subscribe(); ok {
+		t.Error("expected subscribing to a stopped game to get a closed channel")
+	}
 }
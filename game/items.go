@@ -0,0 +1,266 @@
+package game
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// itemsJSON - the items shipped with the game, see data/items.json
+//
+//go:embed data/items.json
+var itemsJSON []byte
+
+// itemCatalog - the registry consulted by the level loader and bestiary loot tables
+var itemCatalog = mustLoadItemCatalog(itemsJSON)
+
+type Item struct {
+	Entity
+	Kind    string
+	Weight  int
+	Heal    int // hitpoints restored when used, 0 if the item can't be used
+	Slot    EquipSlot
+	Attack  int
+	Defense int
+	Speed   float64
+	Damage  Dice    // weapons only, replaces unarmed damage
+	Effect  *Effect // applied to the player when used
+	Light   int     // light radius while carried or lying on the floor
+}
+
+// ItemDef - everything needed to create a kind of item
+// slot is one of head, body, hands, weapon, offhand or ring, empty if it can't be worn
+type ItemDef struct {
+	Kind    string      `json:"kind"`
+	Name    string      `json:"name"`
+	Glyph   string      `json:"glyph"`
+	Weight  int         `json:"weight"`
+	Heal    int         `json:"heal"`
+	Slot    string      `json:"slot"`
+	Attack  int         `json:"attack"`
+	Defense int         `json:"defense"`
+	Speed   float64     `json:"speed"`
+	Damage  string      `json:"damage"`
+	Effect  *EffectSpec `json:"effect"`
+	Light   int         `json:"light"`
+	symbol  rune
+	effect  *Effect
+	damage  Dice
+	slot    EquipSlot
+}
+
+type ItemCatalog struct {
+	kinds map[string]*ItemDef
+}
+
+// LoadItemCatalog - parses and validates an item catalog
+func LoadItemCatalog(r io.Reader) (*ItemCatalog, error) {
+	file := struct {
+		Items []*ItemDef `json:"items"`
+	}{}
+	if err := json.NewDecoder(r).Decode(&file); err != nil {
+		return nil, fmt.Errorf("items: %v", err)
+	}
+
+	c := &ItemCatalog{}
+	c.kinds = make(map[string]*ItemDef)
+	reserved := baseLegend()
+	for i, def := range file.Items {
+		if def.Kind == "" || def.Name == "" {
+			return nil, fmt.Errorf("items: item %d needs a kind and a name", i)
+		}
+		if strings.ContainsAny(def.Kind, " \t") {
+			return nil, fmt.Errorf("items: kind %q can't contain spaces, it is used in level legends", def.Kind)
+		}
+		glyph := []rune(def.Glyph)
+		if len(glyph) != 1 {
+			return nil, fmt.Errorf("items: %s glyph must be a single character", def.Kind)
+		}
+		def.symbol = glyph[0]
+		if _, exists := reserved[def.symbol]; exists {
+			return nil, fmt.Errorf("items: %s glyph %q is already a tile", def.Kind, def.symbol)
+		}
+		if _, exists := c.kinds[def.Kind]; exists {
+			return nil, fmt.Errorf("items: duplicate kind %s", def.Kind)
+		}
+		if def.Weight < 0 {
+			return nil, fmt.Errorf("items: %s has negative weight", def.Kind)
+		}
+		if def.Slot != "" {
+			slot, exists := slotNames[def.Slot]
+			if !exists {
+				return nil, fmt.Errorf("items: %s has unknown slot %q", def.Kind, def.Slot)
+			}
+			def.slot = slot
+		}
+		if def.Damage != "" {
+			dice, err := ParseDice(def.Damage)
+			if err != nil {
+				return nil, fmt.Errorf("items: %s: %v", def.Kind, err)
+			}
+			def.damage = dice
+		}
+		if def.Effect != nil {
+			effect, err := def.Effect.parse()
+			if err != nil {
+				return nil, fmt.Errorf("items: %s: %v", def.Kind, err)
+			}
+			def.effect = &effect
+		}
+		c.kinds[def.Kind] = def
+	}
+	return c, nil
+}
+
+// LoadItemCatalogFile - reads an item catalog from disk
+func LoadItemCatalogFile(filename string) (*ItemCatalog, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return LoadItemCatalog(file)
+}
+
+// SetItemCatalog - replaces the items used by every level loaded or generated afterwards
+// load a bestiary again afterwards, loot tables are checked against the catalog
+func SetItemCatalog(c *ItemCatalog) {
+	itemCatalog = c
+}
+
+func mustLoadItemCatalog(data []byte) *ItemCatalog {
+	c, err := LoadItemCatalog(bytes.NewReader(data))
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+// Def - looks up an item by kind
+func (c *ItemCatalog) Def(kind string) (*ItemDef, bool) {
+	def, exists := c.kinds[kind]
+	return def, exists
+}
+
+func (def *ItemDef) NewItem(p Pos) *Item {
+	item := &Item{}
+	item.Pos = p
+	item.Kind = def.Kind
+	item.Name = def.Name
+	item.Symbol = def.symbol
+	item.Weight = def.Weight
+	item.Heal = def.Heal
+	item.Slot = def.slot
+	item.Attack = def.Attack
+	item.Defense = def.Defense
+	item.Speed = def.Speed
+	item.Damage = def.damage
+	item.Light = def.Light
+	if def.effect != nil {
+		effect := *def.effect
+		item.Effect = &effect
+	}
+	return item
+}
+
+// Inventory limits for the player
+const (
+	MaxInventorySlots  = 10
+	MaxInventoryWeight = 20
+)
+
+// InventoryWeight - total weight the player is carrying, including what they wear
+func (player *Player) InventoryWeight() int {
+	weight := 0
+	for _, item := range player.Inventory {
+		weight += item.Weight
+	}
+	for _, item := range player.Equipment {
+		if item != nil {
+			weight += item.Weight
+		}
+	}
+	return weight
+}
+
+// pickUp - moves the top item of the pile under the player into the inventory, false if it stays put
+func (level *Level) pickUp() bool {
+	player := level.Player
+	pile := level.Items[player.Pos]
+	if len(pile) == 0 {
+		level.AddEvent("There is nothing here")
+		return false
+	}
+	item := pile[len(pile)-1]
+	if len(player.Inventory) >= MaxInventorySlots {
+		level.AddEvent("Your pack is full")
+		return false
+	}
+	if player.InventoryWeight()+item.Weight > MaxInventoryWeight {
+		level.AddEvent(fmt.Sprintf("The %s is too heavy to carry", item.Name))
+		return false
+	}
+
+	pile = pile[:len(pile)-1]
+	if len(pile) == 0 {
+		delete(level.Items, player.Pos)
+	} else {
+		level.Items[player.Pos] = pile
+	}
+	player.Inventory = append(player.Inventory, item)
+	level.AddEvent(fmt.Sprintf("%s picks up %s", player.Name, item.Name))
+	return true
+}
+
+// drop - puts the item in slot on the ground under the player, false if the slot is empty
+func (level *Level) drop(slot int) bool {
+	player := level.Player
+	item := player.takeItem(slot)
+	if item == nil {
+		level.AddEvent("You have nothing to drop")
+		return false
+	}
+	item.Pos = player.Pos
+	level.Items[player.Pos] = append(level.Items[player.Pos], item)
+	level.AddEvent(fmt.Sprintf("%s drops %s", player.Name, item.Name))
+	return true
+}
+
+// use - uses up the item in slot, false if it can't be used
+func (level *Level) use(slot int) bool {
+	player := level.Player
+	if slot < 0 || slot >= len(player.Inventory) {
+		level.AddEvent("You have nothing to use")
+		return false
+	}
+	item := player.Inventory[slot]
+	if item.Heal == 0 && item.Effect == nil {
+		level.AddEvent(fmt.Sprintf("You can't use the %s", item.Name))
+		return false
+	}
+	player.takeItem(slot)
+	level.AddEvent(fmt.Sprintf("%s uses %s", player.Name, item.Name))
+	if item.Heal > 0 {
+		healed := player.Heal(item.Heal)
+		level.AddEvent(fmt.Sprintf("%s heals %d", player.Name, healed))
+	}
+	if item.Effect != nil {
+		player.AddEffect(*item.Effect)
+		level.AddEvent(fmt.Sprintf("%s is %s", player.Name, item.Effect.Kind))
+	}
+	return true
+}
+
+// takeItem - removes and returns the item in slot, nil if the slot is empty
+func (player *Player) takeItem(slot int) *Item {
+	if slot < 0 || slot >= len(player.Inventory) {
+		return nil
+	}
+	item := player.Inventory[slot]
+	player.Inventory = append(player.Inventory[:slot], player.Inventory[slot+1:]...)
+	return item
+}
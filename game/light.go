@@ -0,0 +1,119 @@
+package game
+
+import "math"
+
+const (
+	minVisibleLight = 0.2   // tiles darker than this can't be seen, except right next to you
+	nightAmbient    = 0.1   // outdoor ambient light at midnight
+	dayLength       = 400.0 // game time from one noon to the next
+	campfireLight   = 8     // light radius of a campfire
+)
+
+// LightSource - something that lights up the tiles around it
+type LightSource struct {
+	Pos
+	Radius int
+}
+
+// LightRadius - how far the player's own light reaches, the brightest thing they carry wins
+func (player *Player) LightRadius() int {
+	radius := player.Light
+	for _, item := range player.Inventory {
+		if item.Light > radius {
+			radius = item.Light
+		}
+	}
+	for _, item := range player.Equipment {
+		if item != nil && item.Light > radius {
+			radius = item.Light
+		}
+	}
+	return radius
+}
+
+// Ambient - light everywhere on the level before light sources are added
+// outdoor levels follow the day, starting at noon
+func (level *Level) Ambient() float64 {
+	if !level.Info.Outdoor {
+		return level.Info.Ambient
+	}
+	daylight := 0.5 + 0.5*math.Cos(2*math.Pi*level.Time/dayLength)
+	return nightAmbient + (level.Info.Ambient-nightAmbient)*daylight
+}
+
+// LightSources - everything giving off light that could reach what the player sees
+func (level *Level) LightSources() []LightSource {
+	player := level.Player
+	reach := player.SightRange + campfireLight
+	sources := make([]LightSource, 0)
+	if radius := player.LightRadius(); radius > 0 {
+		sources = append(sources, LightSource{player.Pos, radius})
+	}
+	for _, pos := range sortedPositions(level.Monsters) {
+		if m := level.Monsters[pos]; m.Light > 0 {
+			sources = append(sources, LightSource{pos, m.Light})
+		}
+	}
+	for _, pos := range sortedItemPositions(level.Items) {
+		for _, item := range level.Items[pos] {
+			if item.Light > 0 {
+				sources = append(sources, LightSource{pos, item.Light})
+				break
+			}
+		}
+	}
+	for y := player.Y - reach; y <= player.Y+reach; y++ {
+		for x := player.X - reach; x <= player.X+reach; x++ {
+			pos := Pos{x, y}
+			if inRange(level, pos) && level.Map[y][x].Symbol == Campfire {
+				sources = append(sources, LightSource{pos, campfireLight})
+			}
+		}
+	}
+	return sources
+}
+
+// lightMap - light added by every light source, shared by everyone looking around during a turn
+type lightMap struct {
+	turn  int
+	added map[Pos]float64
+}
+
+// lighting - the light sources' share of the light, worked out at most once a turn
+// lineOfSight and player actions throw it away, so the player always sees the light as it is
+func (level *Level) lighting() map[Pos]float64 {
+	if level.lights != nil && level.lights.turn == level.Turn {
+		return level.lights.added
+	}
+	added := make(map[Pos]float64)
+	for _, source := range level.LightSources() {
+		for pos := range fov.Compute(level, source.Pos, source.Radius) {
+			added[pos] += 1 - pos.distance(source.Pos)/float64(source.Radius+1)
+		}
+	}
+	level.lights = &lightMap{level.Turn, added}
+	return added
+}
+
+// lightLevels - light falling on each position in area, from 0 to 1
+// lights fade with distance and are blocked by walls the same way sight is
+func (level *Level) lightLevels(area map[Pos]bool) map[Pos]float64 {
+	ambient := level.Ambient()
+	added := level.lighting()
+	light := make(map[Pos]float64, len(area))
+	for pos := range area {
+		light[pos] = math.Min(1, ambient+added[pos])
+	}
+	return light
+}
+
+// lightAt - light falling on a single position
+func (level *Level) lightAt(pos Pos) float64 {
+	return math.Min(1, level.Ambient()+level.lighting()[pos])
+}
+
+// canMakeOut - true if pos is bright enough to see, or close enough to feel around
+func (level *Level) canMakeOut(from, pos Pos, light float64) bool {
+	dx, dy := pos.X-from.X, pos.Y-from.Y
+	return light >= minVisibleLight || dx >= -1 && dx <= 1 && dy >= -1 && dy <= 1
+}
@@ -0,0 +1,445 @@
+package game
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/rdmulford/rirpg/worldgen"
+)
+
+// Level files come in two flavours
+//
+// version 0 is a bare grid of glyphs using DefaultLegend
+//
+// version 1 starts with a header, then an optional legend, then the grid
+//
+//	version 1
+//	name The Cellar
+//	author Riley
+//	size 40 20
+//	seed 7
+//	ambient 0.3
+//	outdoor true
+//	music damp
+//	legend
+//	R monster rat dirt
+//	% item potion
+//	= tile water
+//	map
+//	########...
+//
+// legend entries are "<glyph> <tile|monster|item|player> [name] [floor]" and are
+// added on top of DefaultLegend. monsters, items and the player stand on floor,
+// or the closest floor found if none is given
+const LevelVersion = 1
+
+var (
+	ErrEmptyLevel         = errors.New("level has no rows")
+	ErrInvalidChar        = errors.New("invalid character in map")
+	ErrRaggedRow          = errors.New("row length does not match first row")
+	ErrNoPlayer           = errors.New("level has no player start")
+	ErrMultiplePlayer     = errors.New("level has more than one player start")
+	ErrUnsupportedVersion = errors.New("unsupported level version")
+	ErrBadHeader          = errors.New("malformed header line")
+	ErrBadLegend          = errors.New("malformed legend entry")
+	ErrUnknownTile        = errors.New("unknown tile type")
+	ErrUnknownMonster     = errors.New("unknown monster kind")
+	ErrUnknownItem        = errors.New("unknown item kind")
+	ErrSizeMismatch       = errors.New("map does not match header size")
+)
+
+// LevelError - describes where in a level a parse error happened
+// Line and Col are 1 based, Col counts runes not bytes
+type LevelError struct {
+	Line  int
+	Col   int
+	Glyph rune // offending character, only set for ErrInvalidChar
+	Err   error
+}
+
+func (e *LevelError) Error() string {
+	if e.Line == 0 {
+		return fmt.Sprintf("level: %v", e.Err)
+	}
+	if e.Glyph != 0 {
+		return fmt.Sprintf("level:%d:%d: %v %q", e.Line, e.Col, e.Err, e.Glyph)
+	}
+	return fmt.Sprintf("level:%d:%d: %v", e.Line, e.Col, e.Err)
+}
+
+func (e *LevelError) Unwrap() error {
+	return e.Err
+}
+
+// LevelInfo - metadata from a level header, version 0 levels only know their size
+type LevelInfo struct {
+	Version int
+	Name    string
+	Author  string
+	Width   int
+	Height  int
+	Seed    int64
+	Ambient float64 // 0 is pitch black, 1 is full daylight
+	Outdoor bool    // ambient light follows the time of day, peaking at Ambient
+	Music   string
+}
+
+type LegendKind int
+
+const (
+	LegendTile LegendKind = iota
+	LegendMonster
+	LegendItem
+	LegendPlayer
+)
+
+// LegendEntry - what a single glyph in the map grid turns into
+type LegendEntry struct {
+	Kind LegendKind
+	Tile rune   // tile under the glyph, Pending picks the closest floor
+	Name string // monster or item kind
+}
+
+type Legend map[rune]LegendEntry
+
+// DefaultLegend - the glyphs every level understands, tiles plus every monster in the bestiary
+func DefaultLegend() Legend {
+	legend := baseLegend()
+	for _, def := range bestiary.defs {
+		legend[def.symbol] = LegendEntry{LegendMonster, Pending, def.Kind}
+	}
+	return legend
+}
+
+// baseLegend - glyphs that don't come from the bestiary
+func baseLegend() Legend {
+	return Legend{
+		' ':  {LegendTile, Blank, ""},
+		'\t': {LegendTile, Blank, ""},
+		'#':  {LegendTile, StoneWall, ""},
+		'|':  {LegendTile, ClosedDoor, ""},
+		'/':  {LegendTile, OpenDoor, ""},
+		'.':  {LegendTile, DirtFloor, ""},
+		',':  {LegendTile, Grass, ""},
+		'^':  {LegendTile, Tree, ""},
+		'~':  {LegendTile, Water, ""},
+		'$':  {LegendTile, Sand, ""},
+		'_':  {LegendTile, Road, ""},
+		'*':  {LegendTile, Campfire, ""},
+		'<':  {LegendTile, UpStairs, ""},
+		'>':  {LegendTile, DownStairs, ""},
+		'@':  {LegendPlayer, Pending, ""},
+	}
+}
+
+// tileNames - names designers use for tiles in legends
+var tileNames = map[string]rune{
+	"blank":      Blank,
+	"wall":       StoneWall,
+	"door":       ClosedDoor,
+	"opendoor":   OpenDoor,
+	"dirt":       DirtFloor,
+	"grass":      Grass,
+	"tree":       Tree,
+	"water":      Water,
+	"sand":       Sand,
+	"road":       Road,
+	"campfire":   Campfire,
+	"upstairs":   UpStairs,
+	"downstairs": DownStairs,
+}
+
+// monstersPerLevel - how many monsters worldgen places on a generated level
+const monstersPerLevel = 10
+
+// caveAmbient - light in generated levels below the surface, too dark to see without a light
+const caveAmbient = 0.1
+
+// LoadLevel - reads in and parses a level
+// properly associates each ascii character with a tile (not texture itself)
+func LoadLevel(r io.Reader) (*Level, error) {
+	scanner := bufio.NewScanner(r)
+	levelLines := make([]string, 0)
+	for scanner.Scan() {
+		levelLines = append(levelLines, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(levelLines) == 0 || !strings.HasPrefix(levelLines[0], "version") {
+		info := LevelInfo{Ambient: 1.0}
+		return parseLevel(levelLines, 0, info, DefaultLegend())
+	}
+	info, legend, mapStart, err := parseHeader(levelLines)
+	if err != nil {
+		return nil, err
+	}
+	return parseLevel(levelLines[mapStart:], mapStart, info, legend)
+}
+
+// LoadLevelFromFile - opens and parses a map file
+func LoadLevelFromFile(filename string) (*Level, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return LoadLevel(file)
+}
+
+// GenerateLevel - builds a level from a worldgen map of the given size
+// monsters are picked from the bestiary entries that spawn at depth
+func GenerateLevel(xSize, ySize int, seed int64, depth int) (*Level, error) {
+	monsters := make([]rune, 0, monstersPerLevel)
+	spawns := bestiary.SpawnsAt(depth)
+	r := NewRNG(seed)
+	for i := 0; i < monstersPerLevel && len(spawns) > 0; i++ {
+		monsters = append(monsters, spawns[r.Intn(len(spawns))].symbol)
+	}
+	genMap := worldgen.GenerateNewLevel(xSize, ySize, seed, monsters)
+	levelLines := make([]string, len(genMap))
+	for y, row := range genMap {
+		levelLines[y] = string(row)
+	}
+	// the surface is out in the open, everything below is a dark cave
+	info := LevelInfo{Name: "Wilderness", Seed: seed, Ambient: 1.0, Outdoor: true}
+	if depth > 0 {
+		info = LevelInfo{Name: "Caves", Seed: seed, Ambient: caveAmbient}
+	}
+	return parseLevel(levelLines, 0, info, DefaultLegend())
+}
+
+// parseHeader - reads the header and legend sections of a versioned level
+// returns the index of the first map row
+func parseHeader(levelLines []string) (LevelInfo, Legend, int, error) {
+	info := LevelInfo{Ambient: 1.0}
+	legend := DefaultLegend()
+	inLegend := false
+	for i, line := range levelLines {
+		lineErr := func(err error) (LevelInfo, Legend, int, error) {
+			return info, nil, 0, &LevelError{Line: i + 1, Col: 1, Err: err}
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if inLegend && fields[0] != "map" {
+			entry, err := parseLegendEntry(fields)
+			if err != nil {
+				return lineErr(err)
+			}
+			legend[[]rune(fields[0])[0]] = entry
+			continue
+		}
+
+		value := strings.TrimSpace(strings.TrimPrefix(line, fields[0]))
+		var err error
+		switch fields[0] {
+		case "version":
+			info.Version, err = strconv.Atoi(value)
+			if err == nil && (info.Version < 1 || info.Version > LevelVersion) {
+				err = ErrUnsupportedVersion
+			}
+		case "name":
+			info.Name = value
+		case "author":
+			info.Author = value
+		case "music":
+			info.Music = value
+		case "seed":
+			info.Seed, err = strconv.ParseInt(value, 10, 64)
+		case "ambient":
+			info.Ambient, err = strconv.ParseFloat(value, 64)
+		case "outdoor":
+			info.Outdoor, err = strconv.ParseBool(value)
+		case "size":
+			if len(fields) != 3 {
+				return lineErr(ErrBadHeader)
+			}
+			info.Width, err = strconv.Atoi(fields[1])
+			if err == nil {
+				info.Height, err = strconv.Atoi(fields[2])
+			}
+		case "legend":
+			inLegend = true
+		case "map":
+			return info, legend, i + 1, nil
+		default:
+			return lineErr(ErrBadHeader)
+		}
+		if err == ErrUnsupportedVersion {
+			return lineErr(err)
+		} else if err != nil {
+			return lineErr(ErrBadHeader)
+		}
+	}
+	return info, legend, len(levelLines), nil
+}
+
+// parseLegendEntry - parses "<glyph> <kind> [name] [floor]"
+func parseLegendEntry(fields []string) (LegendEntry, error) {
+	entry := LegendEntry{Tile: Pending}
+	if len([]rune(fields[0])) != 1 || len(fields) < 2 {
+		return entry, ErrBadLegend
+	}
+	args := fields[2:]
+	switch fields[1] {
+	case "tile":
+		entry.Kind = LegendTile
+		if len(args) != 1 {
+			return entry, ErrBadLegend
+		}
+		tile, exists := tileNames[args[0]]
+		if !exists {
+			return entry, ErrUnknownTile
+		}
+		entry.Tile = tile
+		return entry, nil
+	case "monster":
+		entry.Kind = LegendMonster
+		if len(args) == 0 {
+			return entry, ErrBadLegend
+		}
+		if _, exists := bestiary.Def(args[0]); !exists {
+			return entry, ErrUnknownMonster
+		}
+	case "item":
+		entry.Kind = LegendItem
+		if len(args) == 0 {
+			return entry, ErrBadLegend
+		}
+		if _, exists := itemCatalog.Def(args[0]); !exists {
+			return entry, ErrUnknownItem
+		}
+	case "player":
+		entry.Kind = LegendPlayer
+		// player has no name, the only argument is the floor
+		args = append([]string{""}, args...)
+	default:
+		return entry, ErrBadLegend
+	}
+
+	entry.Name = args[0]
+	switch len(args) {
+	case 1:
+	case 2:
+		tile, exists := tileNames[args[1]]
+		if !exists {
+			return entry, ErrUnknownTile
+		}
+		entry.Tile = tile
+	default:
+		return entry, ErrBadLegend
+	}
+	return entry, nil
+}
+
+// parseLevel - turns rows of glyphs into a level, every row must be the same width
+// lineOffset is the number of file lines before the first row, used for error reporting
+func parseLevel(levelLines []string, lineOffset int, info LevelInfo, legend Legend) (*Level, error) {
+	if len(levelLines) == 0 {
+		return nil, &LevelError{Err: ErrEmptyLevel}
+	}
+
+	rows := make([][]rune, len(levelLines))
+	for y, line := range levelLines {
+		rows[y] = []rune(line)
+		if len(rows[y]) != len(rows[0]) {
+			col := len(rows[0]) + 1
+			if len(rows[y]) < len(rows[0]) {
+				col = len(rows[y]) + 1
+			}
+			return nil, &LevelError{Line: lineOffset + y + 1, Col: col, Err: ErrRaggedRow}
+		}
+	}
+
+	// size is optional in the header, but must be right if given
+	if info.Width == 0 && info.Height == 0 {
+		info.Width = len(rows[0])
+		info.Height = len(rows)
+	} else if info.Width != len(rows[0]) || info.Height != len(rows) {
+		return nil, &LevelError{Line: lineOffset + 1, Col: 1, Err: ErrSizeMismatch}
+	}
+
+	level := newLevel(len(rows[0]), len(rows))
+	level.Info = info
+	level.RNG = NewRNG(info.Seed)
+	level.AI = NewRNG(info.Seed + 1)
+	foundPlayer := false
+	for y, row := range rows {
+		for x, c := range row {
+			entry, exists := legend[c]
+			if !exists {
+				return nil, &LevelError{Line: lineOffset + y + 1, Col: x + 1, Glyph: c, Err: ErrInvalidChar}
+			}
+			if entry.Kind == LegendPlayer {
+				if foundPlayer {
+					return nil, &LevelError{Line: lineOffset + y + 1, Col: x + 1, Err: ErrMultiplePlayer}
+				}
+				foundPlayer = true
+			}
+			level.placeEntry(entry, Pos{x, y})
+		}
+	}
+	if !foundPlayer {
+		return nil, &LevelError{Err: ErrNoPlayer}
+	}
+
+	// Handle pending tiles by setting floor to closest floor type found (or dirt for default)
+	for y, row := range level.Map {
+		for x, tile := range row {
+			if tile.Symbol == Pending {
+				level.Map[y][x] = level.bfsFloor(Pos{x, y})
+			}
+		}
+	}
+
+	level.lineOfSight()
+
+	return level, nil
+}
+
+// newLevel - allocates an empty level, the player is placed by the loader
+func newLevel(xSize, ySize int) *Level {
+	level := &Level{}
+	level.Player = NewPlayer(Pos{})
+	level.Map = make([][]Tile, ySize)
+	level.Monsters = make(map[Pos]*Monster)
+	level.Items = make(map[Pos][]*Item)
+	level.Trees = make(map[Pos]Tile)
+	level.Events = make([]string, 10) // 10 = number of events that fit on screen at a time
+	level.Debug = make(map[Pos]string)
+	level.RNG = NewRNG(0)
+	level.AI = NewRNG(0)
+
+	for i := range level.Map {
+		level.Map[i] = make([]Tile, xSize)
+	}
+	return level
+}
+
+// placeEntry - sets the tile at pos and spawns anything standing on it
+func (level *Level) placeEntry(entry LegendEntry, pos Pos) {
+	t := Tile{Symbol: entry.Tile}
+	switch entry.Kind {
+	case LegendPlayer:
+		level.Player.Pos = pos
+		level.Start = pos
+	case LegendMonster:
+		def, _ := bestiary.Def(entry.Name)
+		level.Monsters[pos] = def.NewMonster(pos)
+	case LegendItem:
+		def, _ := itemCatalog.Def(entry.Name)
+		level.Items[pos] = append(level.Items[pos], def.NewItem(pos))
+	}
+	if t.Symbol == Tree {
+		level.Trees[pos] = t
+	}
+	level.Map[pos.Y][pos.X] = t
+}
@@ -2,61 +2,56 @@ package game
 
 import (
 	"fmt"
-	"math"
-	"os"
-
-	"github.com/veandco/go-sdl2/sdl"
 )
 
 type Monster struct {
 	Character
+	Kind        string // bestiary kind the monster was spawned from
+	Behaviour   string
+	OnHit       *Effect // applied to whoever this monster hits
+	OnHitChance float64
+	State       AIState
+	LastSeen    Pos // where the player was last seen
+	Target      Pos // where a wandering monster is heading
+	SearchTurns int // turns left searching around LastSeen
 }
 
-func NewRat(p Pos) *Monster {
-	monster := &Monster{}
-	monster.Pos = p
-	monster.Symbol = 'R'
-	monster.Name = "Rat"
-	monster.Hitpoints = 50
-	monster.Strength = 5
-	monster.Speed = 2.0
-	monster.ActionPoints = 0.0
-	monster.MaxBreath = 6
-	monster.CurrentBreath = monster.MaxBreath
-	monster.SightRange = 10
-	return monster
-}
-
-func NewSpider(p Pos) *Monster {
-	monster := &Monster{}
-	monster.Pos = p
-	monster.Symbol = 'S'
-	monster.Name = "Spider"
-	monster.Hitpoints = 100
-	monster.Strength = 10
-	monster.Speed = 1.0
-	monster.ActionPoints = 0.0
-	monster.MaxBreath = 3
-	monster.CurrentBreath = monster.MaxBreath
-	monster.SightRange = 10
-	return monster
+// killMonster - removes a dead monster from the level and drops its loot
+func (level *Level) killMonster(m *Monster) {
+	delete(level.Monsters, m.Pos)
+	level.AddEvent(fmt.Sprintf("%s is dead", m.Name))
+	def, exists := bestiary.Def(m.Kind)
+	if !exists {
+		return
+	}
+	for _, item := range def.rollLoot(m.Pos, level.RNG) {
+		level.Items[m.Pos] = append(level.Items[m.Pos], item)
+		level.AddEvent(fmt.Sprintf("%s drops %s", m.Name, item.Name))
+	}
 }
 
+// Update - decides what to do based on what the monster can see, then takes a single action
 func (m *Monster) Update(level *Level) {
-	m.ActionPoints += m.Speed
-	path := level.astar(m.Pos, level.Player.Pos)
-	if len(path) == 0 {
+	if level.Player.IsDead() {
+		return
+	}
+	m.think(level)
+	if m.State == Asleep {
 		m.Pass()
 		return
 	}
-	cost := math.Trunc((math.Min(m.ActionPoints, float64(len(path)-1))))
-	m.Move(path[int(cost)], level)
-	m.ActionPoints -= cost
+	from := m.Pos
+	m.act(level)
+	if m.Pos != from {
+		m.spendTime(stepTime(level, m.Pos))
+	} else {
+		m.spendTime(ActionCost)
+	}
 }
 
 // monster pass thier turn
 func (m *Monster) Pass() {
-	m.ActionPoints -= m.Speed
+	m.spendTime(ActionCost)
 }
 
 func (m *Monster) Move(to Pos, level *Level) {
@@ -68,29 +63,20 @@ func (m *Monster) Move(to Pos, level *Level) {
 		m.Pos = to
 	} else {
 		if m.Pos.IsNextToPlayer(level) {
-			level.Attack(&m.Character, &level.Player.Character)
+			result := level.Attack(&m.Character, &level.Player.Character)
+			if result.Hit && m.OnHit != nil && (m.OnHitChance == 0 || level.RNG.Float64() < m.OnHitChance) {
+				level.Player.AddEffect(*m.OnHit)
+				level.AddEvent(fmt.Sprintf("%s is %s", level.Player.Name, m.OnHit.Kind))
+			}
 			// monster died
 			if m.Hitpoints <= 0 {
-				delete(level.Monsters, m.Pos)
-				level.AddEvent(fmt.Sprintf("%s is dead", m.Name))
+				level.killMonster(m)
 			}
 			// player died
-			if level.Player.Hitpoints <= 0 {
-				fmt.Println("Player died")
-				sdl.Quit()
-				os.Exit(1)
+			if level.Player.IsDead() {
+				level.AddEvent("Player died")
+				level.Player.Die(fmt.Sprintf("killed by a %s", m.Name))
 			}
 		}
 	}
-
-	// check if monster is drowning
-	if level.Map[m.Pos.Y][m.Pos.X].Symbol == '~' {
-		m.CurrentBreath -= 1
-		if m.CurrentBreath < 0 {
-			delete(level.Monsters, m.Pos)
-			level.AddEvent(fmt.Sprintf("%s is dead", m.Name))
-		}
-	} else {
-		m.CurrentBreath = m.MaxBreath
-	}
 }
@@ -0,0 +1,76 @@
+package game
+
+// Connectivity - which neighbouring tiles can be stepped to, chosen per game
+type Connectivity int
+
+const (
+	FourWay  Connectivity = 4
+	EightWay Connectivity = 8
+)
+
+var orthogonals = []Pos{{1, 0}, {-1, 0}, {0, -1}, {0, 1}}
+var diagonals = []Pos{{-1, -1}, {1, -1}, {-1, 1}, {1, 1}}
+
+// inputDirections - the step each movement input takes
+var inputDirections = map[InputType]Pos{
+	Up:        {0, -1},
+	Down:      {0, 1},
+	Left:      {-1, 0},
+	Right:     {1, 0},
+	UpLeft:    {-1, -1},
+	UpRight:   {1, -1},
+	DownLeft:  {-1, 1},
+	DownRight: {1, 1},
+}
+
+// isMovement - true for the directional inputs
+func (typ InputType) isMovement() bool {
+	_, exists := inputDirections[typ]
+	return exists
+}
+
+// directions - every step allowed on this level, orthogonal steps first
+func (level *Level) directions() []Pos {
+	if level.Connectivity == EightWay {
+		return append(append([]Pos{}, orthogonals...), diagonals...)
+	}
+	return orthogonals
+}
+
+// canStep - true if from and to are neighbours under the level's connectivity
+// diagonal steps can't cut the corner of a wall or squeeze through a doorway
+// whether to itself can be walked on is left to the caller
+func (level *Level) canStep(from, to Pos) bool {
+	dx, dy := to.X-from.X, to.Y-from.Y
+	if dx < -1 || dx > 1 || dy < -1 || dy > 1 || dx == 0 && dy == 0 {
+		return false
+	}
+	if dx == 0 || dy == 0 {
+		return true
+	}
+	if level.Connectivity != EightWay {
+		return false
+	}
+	for _, pos := range []Pos{from, to, {from.X + dx, from.Y}, {from.X, from.Y + dy}} {
+		if !inRange(level, pos) || isDoor(level.Map[pos.Y][pos.X].Symbol) {
+			return false
+		}
+	}
+	return canPass(level, Pos{from.X + dx, from.Y}) && canPass(level, Pos{from.X, from.Y + dy})
+}
+
+// steps - every passable position one step away from pos, monsters are ignored
+func (level *Level) steps(pos Pos) []Pos {
+	steps := make([]Pos, 0, 8)
+	for _, dir := range level.directions() {
+		next := Pos{pos.X + dir.X, pos.Y + dir.Y}
+		if canPass(level, next) && level.canStep(pos, next) {
+			steps = append(steps, next)
+		}
+	}
+	return steps
+}
+
+func isDoor(symbol rune) bool {
+	return symbol == ClosedDoor || symbol == OpenDoor
+}
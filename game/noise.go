@@ -0,0 +1,50 @@
+package game
+
+import "fmt"
+
+// noise volumes, monsters within this many tiles hear the noise
+const (
+	walkNoise   = 4
+	runNoise    = 10
+	doorNoise   = 6
+	combatNoise = 8
+)
+
+// runCost - fraction of a normal move's time that a running step takes
+const runCost = 0.5
+
+// makeNoise - wakes or alerts every monster close enough to hear pos
+func (level *Level) makeNoise(pos Pos, volume int) {
+	if volume <= 0 {
+		return
+	}
+	level.Debug[pos] = fmt.Sprintf("noise %d", volume)
+	for _, m := range level.Monsters {
+		if m.Pos != pos && m.Pos.distance(pos) <= float64(volume) {
+			m.hear(level, pos)
+		}
+	}
+}
+
+// hear - sends the monster to investigate a noise, monsters already fighting or fleeing ignore it
+func (m *Monster) hear(level *Level, pos Pos) {
+	switch m.State {
+	case Asleep, Wandering, Searching:
+		m.LastSeen = pos
+		m.SearchTurns = searchTurns
+		m.setState(level, Searching)
+	}
+}
+
+// seesPlayer - true if the player is in the monster's field of view and lit well enough to spot
+// stealthy players have to come closer before they are noticed
+func (m *Monster) seesPlayer(level *Level) bool {
+	player := level.Player
+	if m.Pos.distance(player.Pos) > float64(m.SightRange-player.Stealth) {
+		return false
+	}
+	if !level.canMakeOut(m.Pos, player.Pos, level.lightAt(player.Pos)) {
+		return false
+	}
+	return level.FieldOfView(&m.Character)[player.Pos]
+}
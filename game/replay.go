@@ -0,0 +1,199 @@
+package game
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// ReplayVersion - bump when replays recorded by older versions can no longer play back
+const ReplayVersion = 1
+
+var (
+	ErrReplayVersion  = errors.New("replay was recorded by a newer version of the game")
+	ErrReplayHeader   = errors.New("replay doesn't start with a header")
+	ErrReplayMismatch = errors.New("replay ended in a different state than it was recorded in")
+)
+
+// ReplayHeader - everything needed to start the recorded game again
+// replays always start from a new game, not from a save
+type ReplayHeader struct {
+	Version      int
+	Seed         int64
+	LevelPaths   map[int]string
+	VictoryDepth int
+	Connectivity Connectivity
+}
+
+// ReplayInput - an Input without the ui plumbing
+type ReplayInput struct {
+	Typ  InputType
+	Slot int  `json:",omitempty"`
+	Run  bool `json:",omitempty"`
+}
+
+// replayLine - replay files are one json object per line: the header, each input, then the final state hash
+type replayLine struct {
+	Header *ReplayHeader `json:",omitempty"`
+	Input  *ReplayInput  `json:",omitempty"`
+	Hash   string        `json:",omitempty"`
+}
+
+// Replay - a recorded game, Hash is empty if the recording was cut short
+type Replay struct {
+	Header ReplayHeader
+	Inputs []ReplayInput
+	Hash   string
+}
+
+// Record - starts writing every input Run handles to w, call before Run on a new game
+// the final state hash is written when Run returns
+func (game *Game) Record(w io.Writer) error {
+	world := game.World
+	header := ReplayHeader{ReplayVersion, world.Seed, world.LevelPaths, world.VictoryDepth, world.Connectivity}
+	game.recorder = json.NewEncoder(w)
+	return game.recorder.Encode(replayLine{Header: &header})
+}
+
+// record - adds an input to the recording, if there is one
+func (game *Game) record(input *Input) {
+	if game.recorder == nil || input.Typ == CloseWindow {
+		return
+	}
+	err := game.recorder.Encode(replayLine{Input: &ReplayInput{input.Typ, input.Slot, input.Run}})
+	if err != nil {
+		game.Level.AddEvent(fmt.Sprintf("Recording stopped: %v", err))
+		game.recorder = nil
+	}
+}
+
+// stopRecording - ends the recording with the hash of the final state
+func (game *Game) stopRecording() {
+	if game.recorder == nil {
+		return
+	}
+	hash, err := game.StateHash()
+	if err == nil {
+		game.recorder.Encode(replayLine{Hash: hash})
+	}
+	game.recorder = nil
+}
+
+// StateHash - fingerprint of the whole game state, equal hashes mean equal games
+func (game *Game) StateHash() (string, error) {
+	h := sha256.New()
+	if err := game.Save(h); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// LoadReplay - reads a replay written by Record
+func LoadReplay(r io.Reader) (*Replay, error) {
+	replay := &Replay{}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	first := true
+	for scanner.Scan() {
+		line := replayLine{}
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			return nil, err
+		}
+		if first {
+			if line.Header == nil {
+				return nil, ErrReplayHeader
+			}
+			if line.Header.Version > ReplayVersion {
+				return nil, ErrReplayVersion
+			}
+			replay.Header = *line.Header
+			first = false
+			continue
+		}
+		if line.Input != nil {
+			replay.Inputs = append(replay.Inputs, *line.Input)
+		}
+		if line.Hash != "" {
+			replay.Hash = line.Hash
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if first {
+		return nil, ErrReplayHeader
+	}
+	return replay, nil
+}
+
+// NewGame - starts the game the replay was recorded from
+func (replay *Replay) NewGame() (*Game, error) {
+	header := replay.Header
+	world, err := NewWorld(header.Seed, header.LevelPaths)
+	if err != nil {
+		return nil, err
+	}
+	world.VictoryDepth = header.VictoryDepth
+	world.SetConnectivity(header.Connectivity)
+	return newGame(world), nil
+}
+
+// Play - runs the replay headless through Run as fast as possible
+// returns the finished game, and ErrReplayMismatch if it didn't end in the recorded state
+func (replay *Replay) Play() (*Game, error) {
+	game, err := replay.NewGame()
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	go replay.Feed(ctx, game, 0)
+	game.Run(ctx)
+	// stops feeding if the game stopped before the replay ran out
+	cancel()
+	return game, replay.Verify(game)
+}
+
+// Feed - sends the recorded inputs to a running game with delay between them, then quits it
+// stops early if ctx is cancelled, for when the game stops first
+// returns true if the whole replay was sent
+func (replay *Replay) Feed(ctx context.Context, game *Game, delay time.Duration) bool {
+	for _, input := range replay.Inputs {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(delay):
+		}
+		select {
+		case <-ctx.Done():
+			return false
+		case game.InputChan <- &Input{Typ: input.Typ, Slot: input.Slot, Run: input.Run}:
+		}
+	}
+	select {
+	case <-ctx.Done():
+		return false
+	case game.InputChan <- &Input{Typ: QuitGame}:
+		return true
+	}
+}
+
+// Verify - checks game ended up in the recorded state, replays without a hash always pass
+func (replay *Replay) Verify(game *Game) error {
+	if replay.Hash == "" {
+		return nil
+	}
+	hash, err := game.StateHash()
+	if err != nil {
+		return err
+	}
+	if hash != replay.Hash {
+		return ErrReplayMismatch
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package game
+
+import (
+	"math/rand"
+)
+
+// RNG - a math/rand generator whose state can be saved and restored
+// backed by splitmix64, which keeps its whole state in a single number
+type RNG struct {
+	*rand.Rand
+	source *splitMix
+}
+
+// Stream - each kind of randomness gets its own sequence
+// so, say, an extra roll in combat can't change where the next level's monsters spawn
+type Stream int
+
+const (
+	StreamWorldgen Stream = iota
+	StreamCombat
+	StreamAI
+)
+
+// streamSeed - the seed for one stream on one level, derived from the game seed
+func streamSeed(gameSeed int64, depth int, stream Stream) int64 {
+	s := splitMix{uint64(gameSeed)}
+	s.state ^= s.Uint64() + uint64(depth)
+	s.state ^= s.Uint64() + uint64(stream)
+	return int64(s.Uint64())
+}
+
+func NewRNG(seed int64) *RNG {
+	source := &splitMix{uint64(seed)}
+	return &RNG{rand.New(source), source}
+}
+
+// State - everything needed to continue the sequence later with SetState
+func (r *RNG) State() uint64 {
+	return r.source.state
+}
+
+func (r *RNG) SetState(state uint64) {
+	r.source.state = state
+}
+
+type splitMix struct {
+	state uint64
+}
+
+func (s *splitMix) Seed(seed int64) {
+	s.state = uint64(seed)
+}
+
+func (s *splitMix) Uint64() uint64 {
+	s.state += 0x9e3779b97f4a7c15
+	z := s.state
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return z ^ (z >> 31)
+}
+
+func (s *splitMix) Int63() int64 {
+	return int64(s.Uint64() >> 1)
+}
@@ -0,0 +1,218 @@
+package game
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// SaveVersion - bump when the save format changes in a way old loaders can't ignore
+// fields are only ever added, unknown fields are ignored when loading
+const SaveVersion = 1
+
+var ErrSaveVersion = errors.New("save was written by a newer version of the game")
+
+type saveFile struct {
+	Version      int
+	State        GameState
+	Seed         int64
+	Depth        int
+	VictoryDepth int
+	Connectivity Connectivity
+	LevelPaths   map[int]string
+	Player       Player
+	Levels       []savedLevel
+}
+
+type savedLevel struct {
+	Depth    int
+	Info     LevelInfo
+	Start    Pos
+	Tiles    []string // one string of tile symbols per row
+	Seen     []string // one string per row, '1' where the tile has been seen
+	Monsters []*Monster
+	Items    []*Item
+	Events   []string
+	EventPos int
+	RNG      uint64
+	AI       uint64
+	Time     float64
+	Turn     int
+}
+
+// Save - writes the whole game state to w
+func (game *Game) Save(w io.Writer) error {
+	world := game.World
+	save := saveFile{}
+	save.Version = SaveVersion
+	save.State = game.State
+	save.VictoryDepth = world.VictoryDepth
+	save.Connectivity = world.Connectivity
+	save.Seed = world.Seed
+	save.Depth = world.Depth
+	save.LevelPaths = world.LevelPaths
+	save.Player = *game.Level.Player
+	depths := make([]int, 0, len(world.Levels))
+	for depth := range world.Levels {
+		depths = append(depths, depth)
+	}
+	sort.Ints(depths)
+	for _, depth := range depths {
+		level := world.Levels[depth]
+		saved := savedLevel{Depth: depth, Info: level.Info, Start: level.Start, Events: level.Events, EventPos: level.EventPos, RNG: level.RNG.State(), AI: level.AI.State(), Time: level.Time, Turn: level.Turn}
+		for _, row := range level.Map {
+			tiles := make([]rune, len(row))
+			seen := make([]byte, len(row))
+			for x, tile := range row {
+				tiles[x] = tile.Symbol
+				seen[x] = '0'
+				if tile.Seen {
+					seen[x] = '1'
+				}
+			}
+			saved.Tiles = append(saved.Tiles, string(tiles))
+			saved.Seen = append(saved.Seen, string(seen))
+		}
+		for _, pos := range sortedPositions(level.Monsters) {
+			saved.Monsters = append(saved.Monsters, level.Monsters[pos])
+		}
+		// piles keep their order, the last item is the one on top
+		for _, pos := range sortedItemPositions(level.Items) {
+			saved.Items = append(saved.Items, level.Items[pos]...)
+		}
+		save.Levels = append(save.Levels, saved)
+	}
+	return json.NewEncoder(w).Encode(save)
+}
+
+// Load - reads a game written by Save, the loaded game has no subscribers until uis call Subscribe
+func Load(r io.Reader) (*Game, error) {
+	save := saveFile{}
+	if err := json.NewDecoder(r).Decode(&save); err != nil {
+		return nil, err
+	}
+	if save.Version > SaveVersion {
+		return nil, ErrSaveVersion
+	}
+
+	world := &World{}
+	world.Levels = make(map[int]*Level)
+	world.LevelPaths = save.LevelPaths
+	world.Seed = save.Seed
+	world.Depth = save.Depth
+	world.VictoryDepth = save.VictoryDepth
+	world.Connectivity = save.Connectivity
+	// saves from before diagonal movement
+	if world.Connectivity == 0 {
+		world.Connectivity = FourWay
+	}
+	if world.LevelPaths == nil {
+		world.LevelPaths = make(map[int]string)
+	}
+
+	player := &Player{}
+	*player = save.Player
+	// saves from before max hitpoints and experience existed
+	if player.MaxHitpoints == 0 {
+		player.MaxHitpoints = player.Hitpoints
+	}
+	if player.ExperienceLevel == 0 {
+		player.ExperienceLevel = 1
+	}
+	for _, saved := range save.Levels {
+		level, err := saved.restore(player)
+		if err != nil {
+			return nil, err
+		}
+		level.Connectivity = world.Connectivity
+		world.Levels[saved.Depth] = level
+	}
+	if world.CurrentLevel() == nil {
+		return nil, fmt.Errorf("save has no level at depth %d", world.Depth)
+	}
+	world.CurrentLevel().lineOfSight()
+
+	game := newGame(world)
+	game.State = save.State
+	return game, nil
+}
+
+// restore - rebuilds a level from its saved form, the player is shared by every level
+func (saved *savedLevel) restore(player *Player) (*Level, error) {
+	if len(saved.Tiles) == 0 || len(saved.Seen) != len(saved.Tiles) {
+		return nil, fmt.Errorf("save has a malformed level at depth %d", saved.Depth)
+	}
+	width := len([]rune(saved.Tiles[0]))
+	level := newLevel(width, len(saved.Tiles))
+	level.Depth = saved.Depth
+	level.Info = saved.Info
+	level.Start = saved.Start
+	level.Player = player
+	level.RNG.SetState(saved.RNG)
+	level.AI.SetState(saved.AI)
+	level.Time = saved.Time
+	level.Turn = saved.Turn
+	for y, row := range saved.Tiles {
+		tiles := []rune(row)
+		if len(tiles) != width || len(saved.Seen[y]) != width {
+			return nil, fmt.Errorf("save has a malformed level at depth %d", saved.Depth)
+		}
+		for x, symbol := range tiles {
+			t := Tile{Symbol: symbol, Seen: saved.Seen[y][x] == '1'}
+			if symbol == Tree {
+				level.Trees[Pos{x, y}] = t
+			}
+			level.Map[y][x] = t
+		}
+	}
+	for _, monster := range saved.Monsters {
+		if monster.MaxHitpoints == 0 {
+			monster.MaxHitpoints = monster.Hitpoints
+		}
+		level.Monsters[monster.Pos] = monster
+	}
+	for _, item := range saved.Items {
+		level.Items[item.Pos] = append(level.Items[item.Pos], item)
+	}
+	if len(saved.Events) > 0 {
+		level.Events = saved.Events
+		level.EventPos = saved.EventPos % len(saved.Events)
+	}
+	return level, nil
+}
+
+// sortedPositions - monster positions in reading order, so saves are stable
+func sortedPositions(monsters map[Pos]*Monster) []Pos {
+	positions := make([]Pos, 0, len(monsters))
+	for pos := range monsters {
+		positions = append(positions, pos)
+	}
+	sortPositions(positions)
+	return positions
+}
+
+// sortedItemPositions - positions of item piles in reading order
+func sortedItemPositions(items map[Pos][]*Item) []Pos {
+	positions := make([]Pos, 0, len(items))
+	for pos := range items {
+		positions = append(positions, pos)
+	}
+	sortPositions(positions)
+	return positions
+}
+
+func sortPositions(positions []Pos) {
+	sort.Slice(positions, func(i, j int) bool {
+		return positions[i].less(positions[j])
+	})
+}
+
+// less - orders positions top to bottom, left to right
+func (pos Pos) less(other Pos) bool {
+	if pos.Y != other.Y {
+		return pos.Y < other.Y
+	}
+	return pos.X < other.X
+}
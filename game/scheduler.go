@@ -0,0 +1,101 @@
+package game
+
+import (
+	"container/heap"
+	"math"
+)
+
+// ActionCost - game time a standard action takes at speed 1
+// faster characters spend less time per action, so they act more often
+const ActionCost = 1.0
+
+// actor - an entry in the turn queue, monster is nil for the player
+type actor struct {
+	character *Character
+	monster   *Monster
+	order     int // breaks ties, the player goes first then monsters in reading order
+}
+
+// turnQueue - actors ordered by the time of their next action
+type turnQueue []actor
+
+func (q turnQueue) Len() int { return len(q) }
+
+func (q turnQueue) Less(i, j int) bool {
+	if q[i].character.NextAction != q[j].character.NextAction {
+		return q[i].character.NextAction < q[j].character.NextAction
+	}
+	return q[i].order < q[j].order
+}
+
+func (q turnQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+
+func (q *turnQueue) Push(x interface{}) { *q = append(*q, x.(actor)) }
+
+func (q *turnQueue) Pop() interface{} {
+	old := *q
+	a := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return a
+}
+
+// spendTime - uses up cost worth of c's time, scaled by c's speed
+func (c *Character) spendTime(cost float64) {
+	c.NextAction += cost / c.ActionSpeed()
+}
+
+// runUntilPlayer - lets every monster whose next action comes before the player's act, one action at a time
+// effects tick once for every whole unit of game time that passes
+func (level *Level) runUntilPlayer() {
+	queue := &turnQueue{actor{character: &level.Player.Character, order: 0}}
+	for i, pos := range sortedPositions(level.Monsters) {
+		m := level.Monsters[pos]
+		heap.Push(queue, actor{character: &m.Character, monster: m, order: i + 1})
+	}
+
+	for queue.Len() > 0 && !level.Player.IsDead() {
+		next := heap.Pop(queue).(actor)
+		level.advanceTime(next.character.NextAction)
+		if level.Player.IsDead() {
+			return
+		}
+		if next.monster == nil {
+			return
+		}
+		// the monster may have died to its effects
+		if level.Monsters[next.monster.Pos] != next.monster {
+			continue
+		}
+		next.monster.Update(level)
+		if level.Monsters[next.monster.Pos] == next.monster {
+			heap.Push(queue, next)
+		}
+	}
+}
+
+// advanceTime - moves the level clock forward to t, ticking effects on every whole turn passed
+func (level *Level) advanceTime(t float64) {
+	if t <= level.Time {
+		return
+	}
+	for level.Turn < int(math.Floor(t)) {
+		level.Turn++
+		level.tickTurn()
+	}
+	level.Time = t
+}
+
+// tickTurn - one turn of effects for everyone on the level
+func (level *Level) tickTurn() {
+	player := level.Player
+	if cause := level.tickEffects(&player.Character); cause != "" {
+		level.AddEvent("Player died")
+		player.Die(cause)
+	}
+	for _, pos := range sortedPositions(level.Monsters) {
+		m := level.Monsters[pos]
+		if cause := level.tickEffects(&m.Character); cause != "" {
+			level.killMonster(m)
+		}
+	}
+}
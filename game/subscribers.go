@@ -0,0 +1,83 @@
+package game
+
+import (
+	"sync"
+)
+
+// inputBuffer - inputs a ui can get ahead of the game by before it has to wait
+const inputBuffer = 16
+
+// subscribers - the uis being sent views, safe to change from any goroutine
+// each ui only ever has the latest view waiting, a slow window skips frames instead of holding up the game
+type subscribers struct {
+	mu      sync.Mutex
+	chans   map[<-chan *View]chan *View
+	latest  *View // handed to uis that subscribe between updates
+	stopped bool  // the game has finished, nothing more will be sent
+}
+
+// Subscribe - starts sending views to a new ui, beginning with the latest one
+// the channel is closed by Unsubscribe or when the game stops
+func (game *Game) Subscribe() <-chan *View {
+	subs := &game.subscribers
+	subs.mu.Lock()
+	defer subs.mu.Unlock()
+	views := make(chan *View, 1)
+	if subs.stopped {
+		close(views)
+		return views
+	}
+	if subs.latest != nil {
+		views <- subs.latest
+	}
+	if subs.chans == nil {
+		subs.chans = make(map[<-chan *View]chan *View)
+	}
+	subs.chans[views] = views
+	return views
+}
+
+// Unsubscribe - stops sending views to a ui and closes its channel
+func (game *Game) Unsubscribe(views <-chan *View) {
+	subs := &game.subscribers
+	subs.mu.Lock()
+	defer subs.mu.Unlock()
+	if c, exists := subs.chans[views]; exists {
+		delete(subs.chans, views)
+		close(c)
+	}
+}
+
+// Subscribers - how many uis are being sent views
+func (game *Game) Subscribers() int {
+	subs := &game.subscribers
+	subs.mu.Lock()
+	defer subs.mu.Unlock()
+	return len(subs.chans)
+}
+
+// publish - replaces whatever view each ui hasn't picked up yet with this one, never blocks
+// only the game goroutine publishes, so a freshly emptied channel always has room
+func (subs *subscribers) publish(view *View) {
+	subs.mu.Lock()
+	defer subs.mu.Unlock()
+	subs.latest = view
+	for _, c := range subs.chans {
+		select {
+		case <-c:
+		default:
+		}
+		c <- view
+	}
+}
+
+// stop - closes every ui's channel so they know the game is over
+func (subs *subscribers) stop() {
+	subs.mu.Lock()
+	defer subs.mu.Unlock()
+	for views, c := range subs.chans {
+		delete(subs.chans, views)
+		close(c)
+	}
+	subs.stopped = true
+}
@@ -0,0 +1,29 @@
+package game
+
+// costScale - tile costs are in tenths of a normal step so pathfinding can stay in integers
+const costScale = 10
+
+// tileCosts - how long it takes to step onto each walkable tile, missing tiles cost a normal step
+// water is priced for the danger of drowning as much as for the time
+var tileCosts = map[rune]int{
+	Road:  7,
+	Sand:  15,
+	Water: 30,
+}
+
+// minTileCost - cheapest step on any tile, keeps the astar heuristic from overestimating
+const minTileCost = 7
+
+// tileCost - cost of stepping onto pos in tenths of a step
+func tileCost(level *Level, pos Pos) int {
+	cost, exists := tileCosts[level.Map[pos.Y][pos.X].Symbol]
+	if !exists {
+		return costScale
+	}
+	return cost
+}
+
+// stepTime - game time taken to step onto pos
+func stepTime(level *Level, pos Pos) float64 {
+	return ActionCost * float64(tileCost(level, pos)) / costScale
+}
@@ -0,0 +1,116 @@
+package game
+
+// View - a copy of everything a renderer shows from a level, taken between inputs
+// nothing in it points back into the level, so uis can hold onto it while the game carries on
+// views are never changed once sent, every ui is handed the same one
+type View struct {
+	Map      [][]Tile
+	Trees    map[Pos]rune
+	Items    map[Pos]rune // the item on top of each pile
+	Monsters map[Pos]rune
+	Debug    map[Pos]string
+	Events   []string // oldest first, blank until there have been enough events to fill the log
+	Player   PlayerView
+	Depth    int
+	Ambient  float64
+	State    GameState
+}
+
+// PlayerView - the player's stats as worked out when the view was taken
+type PlayerView struct {
+	Pos
+	Name                string
+	Hitpoints           int
+	MaxHitpoints        int
+	Strength            int
+	Accuracy            int
+	Evasion             int
+	AttackPower         int
+	Damage              string
+	Defense             int
+	Speed               float64
+	SightRange          int
+	Stealth             int
+	LightRadius         int
+	CurrentBreath       int
+	MaxBreath           int
+	ExperienceLevel     int
+	Experience          int
+	NextLevelExperience int
+	Effects             string
+	CauseOfDeath        string
+	Inventory           []string // item names by slot
+	InventoryWeight     int
+	Equipment           [NumEquipSlots]string // empty if nothing is worn in a slot
+}
+
+// Snapshot - copies what the uis need out of the level
+func (level *Level) Snapshot(state GameState) *View {
+	view := &View{}
+	view.Map = make([][]Tile, len(level.Map))
+	for y, row := range level.Map {
+		view.Map[y] = append([]Tile(nil), row...)
+	}
+	view.Trees = make(map[Pos]rune, len(level.Trees))
+	for pos, tree := range level.Trees {
+		view.Trees[pos] = tree.Symbol
+	}
+	view.Items = make(map[Pos]rune, len(level.Items))
+	for pos, items := range level.Items {
+		if len(items) > 0 {
+			view.Items[pos] = items[len(items)-1].Symbol
+		}
+	}
+	view.Monsters = make(map[Pos]rune, len(level.Monsters))
+	for pos, monster := range level.Monsters {
+		view.Monsters[pos] = monster.Symbol
+	}
+	view.Debug = make(map[Pos]string, len(level.Debug))
+	for pos, note := range level.Debug {
+		view.Debug[pos] = note
+	}
+	view.Events = make([]string, 0, len(level.Events))
+	view.Events = append(view.Events, level.Events[level.EventPos:]...)
+	view.Events = append(view.Events, level.Events[:level.EventPos]...)
+	view.Player = level.Player.view()
+	view.Depth = level.Depth
+	view.Ambient = level.Ambient()
+	view.State = state
+	return view
+}
+
+func (player *Player) view() PlayerView {
+	view := PlayerView{}
+	view.Pos = player.Pos
+	view.Name = player.Name
+	view.Hitpoints = player.Hitpoints
+	view.MaxHitpoints = player.MaxHitpoints
+	view.Strength = player.Strength
+	view.Accuracy = player.Accuracy
+	view.Evasion = player.Evasion
+	view.AttackPower = player.AttackPower()
+	view.Damage = player.DamageDice().String()
+	view.Defense = player.Defense()
+	view.Speed = player.ActionSpeed()
+	view.SightRange = player.SightRange
+	view.Stealth = player.Stealth
+	view.LightRadius = player.LightRadius()
+	view.CurrentBreath = player.CurrentBreath
+	view.MaxBreath = player.MaxBreath
+	view.ExperienceLevel = player.ExperienceLevel
+	view.Experience = player.Experience
+	view.NextLevelExperience = ExperienceForLevel(player.ExperienceLevel + 1)
+	view.Effects = player.DescribeEffects()
+	view.CauseOfDeath = player.CauseOfDeath
+	view.Inventory = make([]string, len(player.Inventory))
+	for i, item := range player.Inventory {
+		view.Inventory[i] = item.Name
+	}
+	view.InventoryWeight = player.InventoryWeight()
+	for slot, item := range player.Equipment {
+		if item != nil {
+			view.Equipment[slot] = item.Name
+		}
+	}
+	return view
+}
@@ -0,0 +1,152 @@
+package game
+
+import (
+	"fmt"
+)
+
+// World - every level of the dungeon, 0 is the surface and depth grows downwards
+// levels are only loaded or generated the first time the player reaches them
+type World struct {
+	Levels       map[int]*Level // levels visited so far
+	LevelPaths   map[int]string // level files to use instead of worldgen
+	Depth        int
+	Seed         int64
+	VictoryDepth int          // reaching this depth wins the game, 0 means never
+	Connectivity Connectivity // movement rules for every level
+}
+
+const DefaultVictoryDepth = 10
+
+// NewWorld - creates a world and loads the surface level
+func NewWorld(seed int64, levelPaths map[int]string) (*World, error) {
+	world := &World{}
+	world.Levels = make(map[int]*Level)
+	world.LevelPaths = levelPaths
+	world.Seed = seed
+	world.VictoryDepth = DefaultVictoryDepth
+	world.Connectivity = FourWay
+	if world.LevelPaths == nil {
+		world.LevelPaths = make(map[int]string)
+	}
+	level, err := world.loadDepth(0)
+	if err != nil {
+		return nil, err
+	}
+	world.Levels[0] = level
+	return world, nil
+}
+
+// SetConnectivity - switches between four and eight way movement on every level
+func (world *World) SetConnectivity(c Connectivity) {
+	world.Connectivity = c
+	for _, level := range world.Levels {
+		level.Connectivity = c
+		level.flow = nil
+	}
+}
+
+// CurrentLevel - the level the player is on
+func (world *World) CurrentLevel() *Level {
+	return world.Levels[world.Depth]
+}
+
+// loadDepth - loads the level file for depth, or generates a new one
+func (world *World) loadDepth(depth int) (*Level, error) {
+	var level *Level
+	var err error
+	path, exists := world.LevelPaths[depth]
+	if exists {
+		level, err = LoadLevelFromFile(path)
+	} else {
+		level, err = GenerateLevel(100, 100, streamSeed(world.Seed, depth, StreamWorldgen), depth)
+	}
+	if err != nil {
+		return nil, err
+	}
+	// a level file's own seed only counts when it is loaded on its own, in a world the game seed decides
+	level.RNG = NewRNG(streamSeed(world.Seed, depth, StreamCombat))
+	level.AI = NewRNG(streamSeed(world.Seed, depth, StreamAI))
+	level.Depth = depth
+	level.Connectivity = world.Connectivity
+	// levels below the surface are entered by the stairs the player starts on
+	if depth > 0 && !exists {
+		level.Map[level.Player.Y][level.Player.X].Symbol = UpStairs
+	}
+	return level, nil
+}
+
+// ChangeDepth - moves the player delta levels down (or up if negative)
+// the player arrives on the matching stairs of the new level, or as close as they can get
+func (world *World) ChangeDepth(delta int) error {
+	depth := world.Depth + delta
+	if depth < 0 {
+		return fmt.Errorf("there is nothing above depth %d", world.Depth)
+	}
+	from := world.CurrentLevel()
+	to, exists := world.Levels[depth]
+	if !exists {
+		var err error
+		to, err = world.loadDepth(depth)
+		if err != nil {
+			return err
+		}
+		world.Levels[depth] = to
+	}
+
+	arrival := DownStairs
+	verb := "ascends"
+	if delta > 0 {
+		arrival = UpStairs
+		verb = "descends"
+	}
+	pos, found := to.findTile(arrival)
+	if !found {
+		pos = to.Start
+	}
+	// a monster may be standing on the stairs
+	pos, found = to.freeTileNear(pos)
+	if !found {
+		return fmt.Errorf("there is nowhere to stand at depth %d", depth)
+	}
+
+	player := from.Player
+	to.Player = player
+	player.Move(pos, to)
+	// the new level's clock carries on from where it was left
+	player.NextAction = to.Time
+	world.Depth = depth
+	to.AddEvent(fmt.Sprintf("%s %s to depth %d", player.Name, verb, depth))
+	return nil
+}
+
+// freeTileNear - the closest tile to pos the player can stand on, false if every tile is taken
+func (level *Level) freeTileNear(pos Pos) (Pos, bool) {
+	frontier := []Pos{pos}
+	visited := map[Pos]bool{pos: true}
+	for len(frontier) > 0 {
+		current := frontier[0]
+		frontier = frontier[1:]
+		if canWalk(level, current) {
+			return current, true
+		}
+		for _, next := range level.steps(current) {
+			if !visited[next] && canPass(level, next) {
+				frontier = append(frontier, next)
+				visited[next] = true
+			}
+		}
+	}
+	return pos, false
+}
+
+// findTile - returns the position of the first tile with the given symbol
+func (level *Level) findTile(symbol rune) (Pos, bool) {
+	for y, row := range level.Map {
+		for x, tile := range row {
+			if tile.Symbol == symbol {
+				return Pos{x, y}, true
+			}
+		}
+	}
+	return Pos{}, false
+}
@@ -2,14 +2,216 @@
 package main
 
 import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
 	"github.com/rdmulford/rirpg/game"
 	"github.com/rdmulford/rirpg/ui2d"
 )
 
+const savePath = "rirpg.sav"
+
+var diagonal = flag.Bool("diagonal", false, "allow eight way movement in new games")
+var seed = flag.Int64("seed", 0, "seed for new games, 0 picks one from the clock")
+var recordPath = flag.String("record", "", "start a new game and record it to this replay file")
+var replayPath = flag.String("replay", "", "play back a replay file instead of playing")
+var replayDelay = flag.Duration("replay-delay", 100*time.Millisecond, "time between inputs when watching a replay")
+var headless = flag.Bool("headless", false, "check a replay without opening a window")
+var views = flag.String("views", "player", "windows to open, any of player, map and debug separated by commas")
+
 func main() {
-	//game := game.NewGame(1, "game/maps/level1.map")
-	game := game.NewGame(1, "")
-	go func() { game.Run() }()
-	ui := ui2d.NewUI(game.InputChan, game.LevelChans[0])
-	ui.Run()
+	flag.Parse()
+	if *replayPath != "" {
+		err := playReplay()
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// recordings have to start from a new game, and don't touch the save
+	var game *game.Game
+	var err error
+	if *recordPath != "" {
+		game, err = newGame()
+	} else {
+		game, err = loadOrNewGame()
+	}
+	if err != nil {
+		panic(err)
+	}
+	if *recordPath != "" {
+		f, err := os.Create(*recordPath)
+		if err != nil {
+			panic(err)
+		}
+		defer f.Close()
+		err = game.Record(f)
+		if err != nil {
+			panic(err)
+		}
+	}
+	// ctrl-c stops the game the same as closing the window, so it still gets saved
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	windows := ui2d.NewManager(game.InputChan)
+	err = openViews(windows, game)
+	if err != nil {
+		panic(err)
+	}
+	done := make(chan struct{})
+	go func() {
+		game.Run(ctx)
+		close(done)
+	}()
+	windows.Run()
+
+	// ui returns once its window is gone, wait for the game to finish the turn
+	<-done
+	if *recordPath != "" {
+		return
+	}
+	err = saveGame(game)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// loadOrNewGame - resumes from the save file if there is one
+func loadOrNewGame() (*game.Game, error) {
+	f, err := os.Open(savePath)
+	if os.IsNotExist(err) {
+		return newGame()
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return game.Load(f)
+}
+
+// newGame - starts a game from the seed flag, or the clock
+func newGame() (*game.Game, error) {
+	//return game.NewGame("game/maps/level1.map")
+	if *seed == 0 {
+		*seed = time.Now().UnixNano()
+	}
+	// print the seed so an interesting game can be played again
+	fmt.Printf("rirpg seed %d\n", *seed)
+	g, err := game.NewGame("", *seed)
+	if err != nil {
+		return nil, err
+	}
+	if *diagonal {
+		g.World.SetConnectivity(game.EightWay)
+	}
+	return g, nil
+}
+
+// playReplay - plays back the replay file headless or in a window, and checks it ends the way it was recorded
+func playReplay() error {
+	f, err := os.Open(*replayPath)
+	if err != nil {
+		return err
+	}
+	replay, err := game.LoadReplay(f)
+	f.Close()
+	if err != nil {
+		return err
+	}
+
+	if *headless {
+		_, err = replay.Play()
+		if err == nil {
+			fmt.Printf("replay of %d inputs matches\n", len(replay.Inputs))
+		}
+		return err
+	}
+
+	g, err := replay.NewGame()
+	if err != nil {
+		return err
+	}
+	// cancelled once the game stops, so the feed and the ui forwarding below stop with it
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	uiInputs := make(chan *game.Input, 1)
+	windows := ui2d.NewManager(uiInputs)
+	err = openViews(windows, g)
+	if err != nil {
+		cancel()
+		return err
+	}
+	go func() {
+		g.Run(ctx)
+		cancel()
+	}()
+	finished := make(chan bool, 1)
+	go func() {
+		finished <- replay.Feed(ctx, g, *replayDelay)
+	}()
+
+	// the ui only gets to close windows, everything else comes from the replay
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case input := <-uiInputs:
+				if input.Typ == game.CloseWindow || input.Typ == game.QuitGame {
+					select {
+					case g.InputChan <- input:
+					case <-ctx.Done():
+					}
+				}
+			}
+		}
+	}()
+	windows.Run()
+	<-ctx.Done()
+
+	if !<-finished {
+		fmt.Println("replay stopped early")
+		return nil
+	}
+	err = replay.Verify(g)
+	if err == nil {
+		fmt.Printf("replay of %d inputs matches\n", len(replay.Inputs))
+	}
+	return err
+}
+
+// openViews - opens a window for each view named by the views flag, every one subscribed to g
+func openViews(windows *ui2d.Manager, g *game.Game) error {
+	for _, name := range strings.Split(*views, ",") {
+		kind, err := ui2d.ParseViewKind(strings.TrimSpace(name))
+		if err != nil {
+			return err
+		}
+		windows.Open(kind, g.Subscribe())
+	}
+	return nil
+}
+
+// saveGame - writes to a temporary file first so a failed save can't eat the old one
+func saveGame(g *game.Game) error {
+	tmpPath := savePath + ".tmp"
+	f, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+	err = g.Save(f)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	err = f.Close()
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, savePath)
 }
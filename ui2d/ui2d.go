@@ -3,9 +3,11 @@ package ui2d
 
 import (
 	"bufio"
+	"fmt"
 	"image/png"
-	"math/rand"
+	"io"
 	"os"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -15,6 +17,8 @@ import (
 )
 
 type ui struct {
+	kind              ViewKind
+	id                uint32 // sdl window id, used to route window events
 	winWidth          int
 	winHeight         int
 	renderer          *sdl.Renderer
@@ -25,9 +29,8 @@ type ui struct {
 	keyboardState     []uint8
 	centerX           int
 	centerY           int
-	r                 *rand.Rand
-	levelChan         chan *game.Level
-	inputChan         chan *game.Input
+	viewChan          <-chan *game.View
+	inputChan         chan<- *game.Input
 	fontSmall         *ttf.Font
 	fontMedium        *ttf.Font
 	fontLarge         *ttf.Font
@@ -35,6 +38,10 @@ type ui struct {
 	str2TexMedium     map[string]*sdl.Texture
 	str2TexLarge      map[string]*sdl.Texture
 	eventBackground   *sdl.Texture
+	selectedSlot      int // inventory slot used by drop and use
+	showSheet         bool
+	lastView          *game.View  // redrawn when ui only state like the character sheet changes
+	closing           *game.Input // quit or close the game hasn't taken yet, sent again every frame
 }
 
 // init - initialize sdl
@@ -51,23 +58,28 @@ func init() {
 	}
 }
 
-func NewUI(inputChan chan *game.Input, levelChan chan *game.Level) *ui {
+// newWindow - opens a window showing views from viewChan, only player cameras send inputs other than closing
+func newWindow(kind ViewKind, inputChan chan<- *game.Input, viewChan <-chan *game.View) *ui {
 	ui := &ui{}
+	ui.kind = kind
 	ui.inputChan = inputChan
-	ui.levelChan = levelChan
-	ui.r = rand.New(rand.NewSource(1))
-	ui.winHeight = 1080
-	ui.winWidth = 1920
+	ui.viewChan = viewChan
+	ui.winWidth = windowSizes[kind].width
+	ui.winHeight = windowSizes[kind].height
 	ui.str2TexSmall = make(map[string]*sdl.Texture)
 	ui.str2TexMedium = make(map[string]*sdl.Texture)
 	ui.str2TexLarge = make(map[string]*sdl.Texture)
 
 	// Initialize window
-	window, err := sdl.CreateWindow("rirpg", 200, 200, int32(ui.winWidth), int32(ui.winHeight), sdl.WINDOW_SHOWN)
+	window, err := sdl.CreateWindow("rirpg "+kind.String(), 200, 200, int32(ui.winWidth), int32(ui.winHeight), sdl.WINDOW_SHOWN)
 	if err != nil {
 		panic(err)
 	}
 	ui.window = window
+	ui.id, err = window.GetID()
+	if err != nil {
+		panic(err)
+	}
 
 	// Initialize renderer
 	ui.renderer, err = sdl.CreateRenderer(ui.window, -1, sdl.RENDERER_ACCELERATED)
@@ -164,31 +176,43 @@ func (ui *ui) stringToTexture(s string, color sdl.Color, size FontSize) *sdl.Tex
 
 // loadTextureIndex - Parse atlas-index.txt file to obtain coordinates for each defined tile
 func (ui *ui) loadTextureIndex() {
-	ui.textureIndex = make(map[rune][]sdl.Rect)
 	infile, err := os.Open("ui2d/assets/tiles/atlas-index.txt")
 	if err != nil {
 		panic(err)
 	}
-	scanner := bufio.NewScanner(infile)
+	defer infile.Close()
+	ui.textureIndex, err = parseTextureIndex(infile)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// parseTextureIndex - reads "<tile> x,y,variations" lines into the sprite sheet rects for each tile
+func parseTextureIndex(r io.Reader) (map[rune][]sdl.Rect, error) {
+	textureIndex := make(map[rune][]sdl.Rect)
+	scanner := bufio.NewScanner(r)
 	for scanner.Scan() {
 		line := scanner.Text()
 		line = strings.TrimSpace(line)
 		tileRune := rune(line[0])
 		xy := line[1:]
 		splitXYC := strings.Split(xy, ",")
+		if len(splitXYC) != 3 {
+			return nil, fmt.Errorf("atlas index line %q wants x,y,variations", line)
+		}
 		x, err := strconv.ParseInt(strings.TrimSpace(splitXYC[0]), 10, 64)
 		if err != nil {
-			panic(err)
+			return nil, err
 		}
 		y, err := strconv.ParseInt(strings.TrimSpace(splitXYC[1]), 10, 64)
 		if err != nil {
-			panic(err)
+			return nil, err
 		}
 
 		// Account for n number of variations of each tile in order to randomly use variations
 		variationCount, err := strconv.ParseInt(strings.TrimSpace(splitXYC[2]), 10, 64)
 		if err != nil {
-			panic(err)
+			return nil, err
 		}
 		var rects []sdl.Rect
 		for i := int64(0); i < variationCount; i++ {
@@ -201,8 +225,9 @@ func (ui *ui) loadTextureIndex() {
 			}
 		}
 
-		ui.textureIndex[tileRune] = rects
+		textureIndex[tileRune] = rects
 	}
+	return textureIndex, scanner.Err()
 }
 
 // imgFileToTexture - Create sdl texture from given image
@@ -250,30 +275,72 @@ func (ui *ui) imgFileToTexture(filename string) *sdl.Texture {
 	return tex
 }
 
-// Draw - Given level information, draw all tiles into the window
-func (ui *ui) Draw(level *game.Level) {
-	// calculate scrolling
+// Draw - Given a view of the level, draw it the way this kind of window shows it
+func (ui *ui) Draw(view *game.View) {
+	ui.renderer.Clear()
+	switch ui.kind {
+	case MapOverview:
+		ui.drawOverview(view)
+	case DebugCamera:
+		offsetX, offsetY := ui.follow(view)
+		ui.drawMap(view, 32, offsetX, offsetY)
+		ui.drawDebugNotes(view)
+	default:
+		offsetX, offsetY := ui.follow(view)
+		ui.drawMap(view, 32, offsetX, offsetY)
+		ui.drawEvents(view)
+		ui.drawInventory(view)
+		if ui.showSheet {
+			ui.drawCharacterSheet(view)
+		}
+		ui.drawStateScreen(view)
+	}
+	ui.renderer.Present()
+}
+
+// follow - scrolls the camera after the player, returns where the top left of the map goes
+func (ui *ui) follow(view *game.View) (int32, int32) {
 	if ui.centerX == -1 && ui.centerY == -1 {
-		ui.centerX = level.Player.X
-		ui.centerY = level.Player.Y
+		ui.centerX = view.Player.X
+		ui.centerY = view.Player.Y
 	}
 	limit := 5
-	if level.Player.X > ui.centerX+limit {
+	if view.Player.X > ui.centerX+limit {
 		ui.centerX++
-	} else if level.Player.X < ui.centerX-limit {
+	} else if view.Player.X < ui.centerX-limit {
 		ui.centerX--
-	} else if level.Player.Y > ui.centerY+limit {
+	} else if view.Player.Y > ui.centerY+limit {
 		ui.centerY++
-	} else if level.Player.Y < ui.centerY-limit {
+	} else if view.Player.Y < ui.centerY-limit {
 		ui.centerY--
 	}
 	offsetX := int32((ui.winWidth / 2) - ui.centerX*32)
 	offsetY := int32((ui.winHeight / 2) - ui.centerY*32)
+	return offsetX, offsetY
+}
 
-	ui.renderer.Clear()
-	ui.r.Seed(1)
+// drawOverview - the whole map shrunk to fit the window
+func (ui *ui) drawOverview(view *game.View) {
+	if len(view.Map) == 0 {
+		return
+	}
+	size := ui.winWidth / len(view.Map[0])
+	if h := ui.winHeight / len(view.Map); h < size {
+		size = h
+	}
+	if size < 1 {
+		size = 1
+	}
+	offsetX := int32(ui.winWidth-size*len(view.Map[0])) / 2
+	offsetY := int32(ui.winHeight-size*len(view.Map)) / 2
+	ui.drawMap(view, int32(size), offsetX, offsetY)
+}
+
+// drawMap - draws the tiles, trees, items, monsters and player at size pixels a tile
+// debug windows tint tiles the debug overlay has notes for
+func (ui *ui) drawMap(view *game.View, size, offsetX, offsetY int32) {
 	// draws all floor tiles
-	for y, row := range level.Map {
+	for y, row := range view.Map {
 		for x, tile := range row {
 			// draw grass under trees
 			// TODO refactor how this works in general
@@ -283,18 +350,16 @@ func (ui *ui) Draw(level *game.Level) {
 			}
 			if tile.Symbol != game.Blank {
 				srcRects := ui.textureIndex[drawnTile]
-				srcRect := srcRects[ui.r.Intn(len(srcRects))]
+				srcRect := srcRects[tileVariant(x, y, len(srcRects))]
 				if tile.Visible || tile.Seen {
-					dstRect := sdl.Rect{int32(x*32) + offsetX, int32(y*32) + offsetY, int32(32), int32(32)}
+					dstRect := sdl.Rect{int32(x)*size + offsetX, int32(y)*size + offsetY, size, size}
 
 					// debug map drawing
 					pos := game.Pos{x, y}
-					if level.Debug[pos] {
+					if ui.kind == DebugCamera && view.Debug[pos] != "" {
 						ui.textureAtlas.SetColorMod(128, 0, 0)
-					} else if tile.Seen && !tile.Visible {
-						ui.textureAtlas.SetColorMod(128, 128, 128)
 					} else {
-						ui.textureAtlas.SetColorMod(255, 255, 255)
+						ui.shade(tile)
 					}
 
 					ui.renderer.Copy(ui.textureAtlas, &srcRect, &dstRect)
@@ -306,40 +371,49 @@ func (ui *ui) Draw(level *game.Level) {
 	// TODO clean up this logic
 	ui.textureAtlas.SetColorMod(255, 255, 255)
 	// draw trees
-	for pos, tree := range level.Trees {
-		if level.Map[pos.Y][pos.X].Seen && !level.Map[pos.Y][pos.X].Visible {
-			ui.textureAtlas.SetColorMod(128, 128, 128)
-		}
-		if level.Map[pos.Y][pos.X].Visible || level.Map[pos.Y][pos.X].Seen {
-			treeSrcRect := ui.textureIndex[tree.Symbol][0]
-			ui.renderer.Copy(ui.textureAtlas, &treeSrcRect, &sdl.Rect{int32(pos.X)*32 + offsetX, int32(pos.Y)*32 + offsetY, 32, 32})
+	for pos, tree := range view.Trees {
+		ui.shade(view.Map[pos.Y][pos.X])
+		if view.Map[pos.Y][pos.X].Visible || view.Map[pos.Y][pos.X].Seen {
+			treeSrcRect := ui.textureIndex[tree][0]
+			ui.renderer.Copy(ui.textureAtlas, &treeSrcRect, &sdl.Rect{int32(pos.X)*size + offsetX, int32(pos.Y)*size + offsetY, size, size})
 		}
 		ui.textureAtlas.SetColorMod(255, 255, 255)
 	}
 
+	// draw items, only the top of each pile
+	for pos, item := range view.Items {
+		if view.Map[pos.Y][pos.X].Visible {
+			ui.shade(view.Map[pos.Y][pos.X])
+			itemSrcRect := ui.textureIndex[item][0]
+			ui.renderer.Copy(ui.textureAtlas, &itemSrcRect, &sdl.Rect{int32(pos.X)*size + offsetX, int32(pos.Y)*size + offsetY, size, size})
+		}
+	}
+
 	// draw monsters
-	for pos, monster := range level.Monsters {
-		if level.Map[pos.Y][pos.X].Visible {
-			monsterSrcRect := ui.textureIndex[monster.Symbol][0]
-			ui.renderer.Copy(ui.textureAtlas, &monsterSrcRect, &sdl.Rect{int32(pos.X)*32 + offsetX, int32(pos.Y)*32 + offsetY, 32, 32})
+	for pos, monster := range view.Monsters {
+		if view.Map[pos.Y][pos.X].Visible {
+			ui.shade(view.Map[pos.Y][pos.X])
+			monsterSrcRect := ui.textureIndex[monster][0]
+			ui.renderer.Copy(ui.textureAtlas, &monsterSrcRect, &sdl.Rect{int32(pos.X)*size + offsetX, int32(pos.Y)*size + offsetY, size, size})
 		}
 	}
 
 	// draws player
+	ui.textureAtlas.SetColorMod(255, 255, 255)
 	playerSrcRect := ui.textureIndex['@'][0]
-	ui.renderer.Copy(ui.textureAtlas, &playerSrcRect, &sdl.Rect{int32(level.Player.X)*32 + offsetX, int32(level.Player.Y)*32 + offsetY, 32, 32})
+	ui.renderer.Copy(ui.textureAtlas, &playerSrcRect, &sdl.Rect{int32(view.Player.X)*size + offsetX, int32(view.Player.Y)*size + offsetY, size, size})
+}
 
+// drawEvents - the message log in the bottom left with the player's effects above it
+func (ui *ui) drawEvents(view *game.View) {
 	// draw text events
 	// TODO scroll better
 	textStart := int32(float64(ui.winHeight) * 0.68)
 	textWidth := int32(float64(ui.winWidth) * 0.25)
 	// draw text event background
 	ui.renderer.Copy(ui.eventBackground, nil, &sdl.Rect{0, textStart, textWidth, int32(ui.winHeight) - textStart})
-	i := level.EventPos
-	count := 0
 	_, fontSizeY, _ := ui.fontSmall.SizeUTF8("A")
-	for {
-		event := level.Events[i]
+	for count, event := range view.Events {
 		if event != "" {
 			tex := ui.stringToTexture(event, sdl.Color{255, 0, 0, 0}, FontSmall)
 			_, _, w, h, err := tex.Query()
@@ -348,14 +422,199 @@ func (ui *ui) Draw(level *game.Level) {
 			}
 			ui.renderer.Copy(tex, nil, &sdl.Rect{5, int32(count*fontSizeY) + textStart, w, h})
 		}
-		i = (i + 1) % len(level.Events)
-		count++
-		if i == level.EventPos {
-			break
+	}
+
+	// active effects sit just above the events
+	if effects := view.Player.Effects; effects != "" {
+		tex := ui.stringToTexture(effects, sdl.Color{255, 0, 0, 0}, FontSmall)
+		_, _, w, h, err := tex.Query()
+		if err != nil {
+			panic(err)
 		}
+		ui.renderer.Copy(ui.eventBackground, nil, &sdl.Rect{0, textStart - h, w + 10, h})
+		ui.renderer.Copy(tex, nil, &sdl.Rect{5, textStart - h, w, h})
 	}
+}
 
-	ui.renderer.Present()
+// drawDebugNotes - lists every debug note down the left of the window
+func (ui *ui) drawDebugNotes(view *game.View) {
+	notes := make([]string, 0, len(view.Debug))
+	for pos, note := range view.Debug {
+		notes = append(notes, fmt.Sprintf("%d,%d %s", pos.X, pos.Y, note))
+	}
+	sort.Strings(notes)
+	_, fontSizeY, _ := ui.fontSmall.SizeUTF8("A")
+	for i, note := range notes {
+		tex := ui.stringToTexture(note, sdl.Color{255, 0, 0, 0}, FontSmall)
+		_, _, w, h, err := tex.Query()
+		if err != nil {
+			panic(err)
+		}
+		ui.renderer.Copy(ui.eventBackground, nil, &sdl.Rect{0, int32(i * fontSizeY), w + 10, h})
+		ui.renderer.Copy(tex, nil, &sdl.Rect{5, int32(i * fontSizeY), w, h})
+	}
+}
+
+// tileVariant - picks one of n looks for the tile at x, y
+// worked out from the position so the map looks the same every frame without a random generator
+func tileVariant(x, y, n int) int {
+	h := uint32(x)*73856093 ^ uint32(y)*19349663
+	return int(h % uint32(n))
+}
+
+// shade - darkens the atlas by how brightly lit a tile is, remembered tiles are drawn dim and cold
+func (ui *ui) shade(tile game.Tile) {
+	if !tile.Visible {
+		ui.textureAtlas.SetColorMod(48, 48, 64)
+		return
+	}
+	brightness := uint8(64 + 191*tile.Light)
+	ui.textureAtlas.SetColorMod(brightness, brightness, brightness)
+}
+
+// drawInventory - lists the player's items in the top right, the selected slot is marked
+func (ui *ui) drawInventory(view *game.View) {
+	player := view.Player
+	panelWidth := int32(float64(ui.winWidth) * 0.2)
+	panelX := int32(ui.winWidth) - panelWidth
+	_, fontSizeY, _ := ui.fontSmall.SizeUTF8("A")
+	panelHeight := int32(fontSizeY * (game.MaxInventorySlots + int(game.NumEquipSlots) + 1))
+	ui.renderer.Copy(ui.eventBackground, nil, &sdl.Rect{panelX, 0, panelWidth, panelHeight})
+
+	header := fmt.Sprintf("Pack %d/%d", player.InventoryWeight, game.MaxInventoryWeight)
+	tex := ui.stringToTexture(header, sdl.Color{255, 0, 0, 0}, FontSmall)
+	_, _, w, h, err := tex.Query()
+	if err != nil {
+		panic(err)
+	}
+	ui.renderer.Copy(tex, nil, &sdl.Rect{panelX + 5, 0, w, h})
+	for i, item := range player.Inventory {
+		line := fmt.Sprintf("  %d %s", (i+1)%10, item)
+		if i == ui.selectedSlot {
+			line = fmt.Sprintf("> %d %s", (i+1)%10, item)
+		}
+		tex := ui.stringToTexture(line, sdl.Color{255, 0, 0, 0}, FontSmall)
+		_, _, w, h, err := tex.Query()
+		if err != nil {
+			panic(err)
+		}
+		ui.renderer.Copy(tex, nil, &sdl.Rect{panelX + 5, int32((i + 1) * fontSizeY), w, h})
+	}
+
+	// equipment goes below the pack, F keys take items off
+	for slot := game.SlotHead; slot < game.NumEquipSlots; slot++ {
+		item := player.Equipment[slot]
+		if item == "" {
+			continue
+		}
+		line := fmt.Sprintf("F%d %s: %s", slot, slot, item)
+		tex := ui.stringToTexture(line, sdl.Color{255, 0, 0, 0}, FontSmall)
+		_, _, w, h, err := tex.Query()
+		if err != nil {
+			panic(err)
+		}
+		ui.renderer.Copy(tex, nil, &sdl.Rect{panelX + 5, int32((game.MaxInventorySlots + int(slot)) * fontSizeY), w, h})
+	}
+}
+
+// drawCharacterSheet - the player's level and stats in the middle of the screen
+func (ui *ui) drawCharacterSheet(view *game.View) {
+	player := view.Player
+	lines := []string{
+		player.Name,
+		fmt.Sprintf("Level %d", player.ExperienceLevel),
+		fmt.Sprintf("Experience %d / %d", player.Experience, player.NextLevelExperience),
+		fmt.Sprintf("Hitpoints %d / %d", player.Hitpoints, player.MaxHitpoints),
+		fmt.Sprintf("Strength %d", player.Strength),
+		fmt.Sprintf("Attack %d + %s", player.AttackPower, player.Damage),
+		fmt.Sprintf("Defense %d", player.Defense),
+		fmt.Sprintf("Accuracy %d", player.Accuracy),
+		fmt.Sprintf("Evasion %d", player.Evasion),
+		fmt.Sprintf("Speed %.2f", player.Speed),
+		fmt.Sprintf("Sight %d", player.SightRange),
+		fmt.Sprintf("Stealth %d", player.Stealth),
+		fmt.Sprintf("Light %d, ambient %.0f%%", player.LightRadius, view.Ambient*100),
+		fmt.Sprintf("Breath %d / %d", player.CurrentBreath, player.MaxBreath),
+	}
+	if effects := player.Effects; effects != "" {
+		lines = append(lines, effects)
+	}
+
+	_, fontSizeY, _ := ui.fontMedium.SizeUTF8("A")
+	width := int32(float64(ui.winWidth) * 0.3)
+	height := int32(fontSizeY * (len(lines) + 1))
+	x := int32(ui.winWidth)/2 - width/2
+	y := int32(ui.winHeight)/2 - height/2
+	ui.renderer.Copy(ui.eventBackground, nil, &sdl.Rect{x, y, width, height})
+	for i, line := range lines {
+		tex := ui.stringToTexture(line, sdl.Color{255, 0, 0, 0}, FontMedium)
+		_, _, w, h, err := tex.Query()
+		if err != nil {
+			panic(err)
+		}
+		ui.renderer.Copy(tex, nil, &sdl.Rect{x + 10, y + int32(fontSizeY/2+i*fontSizeY), w, h})
+	}
+}
+
+// drawStateScreen - darkens the map and explains what happened when the game isn't being played
+func (ui *ui) drawStateScreen(view *game.View) {
+	var lines []string
+	switch view.State {
+	case game.Dead:
+		lines = []string{"You died", view.Player.CauseOfDeath, "Press R to restart"}
+	case game.Victory:
+		lines = []string{"You escaped the dungeon", "Press R to play again"}
+	case game.Paused:
+		lines = []string{"Paused", "Press P to continue"}
+	default:
+		return
+	}
+
+	ui.renderer.Copy(ui.eventBackground, nil, nil)
+	y := int32(ui.winHeight / 3)
+	for i, line := range lines {
+		if line == "" {
+			continue
+		}
+		size := FontMedium
+		if i == 0 {
+			size = FontLarge
+		}
+		tex := ui.stringToTexture(line, sdl.Color{255, 0, 0, 0}, size)
+		_, _, w, h, err := tex.Query()
+		if err != nil {
+			panic(err)
+		}
+		ui.renderer.Copy(tex, nil, &sdl.Rect{int32(ui.winWidth)/2 - w/2, y, w, h})
+		y += h * 2
+	}
+}
+
+// movementKeys - arrows, the numpad and vi keys all move, diagonals only do anything on eight way games
+var movementKeys = []struct {
+	typ  game.InputType
+	keys []uint8
+}{
+	{game.Up, []uint8{sdl.SCANCODE_UP, sdl.SCANCODE_KP_8, sdl.SCANCODE_K}},
+	{game.Down, []uint8{sdl.SCANCODE_DOWN, sdl.SCANCODE_KP_2, sdl.SCANCODE_J}},
+	{game.Left, []uint8{sdl.SCANCODE_LEFT, sdl.SCANCODE_KP_4, sdl.SCANCODE_H}},
+	{game.Right, []uint8{sdl.SCANCODE_RIGHT, sdl.SCANCODE_KP_6, sdl.SCANCODE_L}},
+	{game.UpLeft, []uint8{sdl.SCANCODE_KP_7, sdl.SCANCODE_Y}},
+	{game.UpRight, []uint8{sdl.SCANCODE_KP_9, sdl.SCANCODE_U}},
+	{game.DownLeft, []uint8{sdl.SCANCODE_KP_1, sdl.SCANCODE_B}},
+	{game.DownRight, []uint8{sdl.SCANCODE_KP_3, sdl.SCANCODE_N}},
+}
+
+// movementKey - the movement input for whichever movement key was just pressed
+func (ui *ui) movementKey() (game.InputType, bool) {
+	for _, binding := range movementKeys {
+		for _, key := range binding.keys {
+			if ui.keyDownOnce(key) {
+				return binding.typ, true
+			}
+		}
+	}
+	return game.None, false
 }
 
 // key pressed
@@ -368,6 +627,17 @@ func (ui *ui) keyPressed(key uint8) bool {
 	return ui.keyboardState[key] == 0 && ui.prevKeyboardState[key] == 1
 }
 
+// send - hands an input to the game without ever holding up the window
+// returns false if the game is too far behind to take it, the input is dropped
+func (ui *ui) send(input *game.Input) bool {
+	select {
+	case ui.inputChan <- input:
+		return true
+	default:
+		return false
+	}
+}
+
 // utility function
 func (ui *ui) GetSinglePixelTex(color sdl.Color) *sdl.Texture {
 	tex, err := ui.renderer.CreateTexture(sdl.PIXELFORMAT_ABGR8888, sdl.TEXTUREACCESS_STATIC, 1, 1)
@@ -383,51 +653,89 @@ func (ui *ui) GetSinglePixelTex(color sdl.Color) *sdl.Texture {
 	return tex
 }
 
-func (ui *ui) Run() {
-	for {
-		// TODO if we want multiple uis, need to seperate this into diffrent component on main thread
-		for event := sdl.PollEvent(); event != nil; event = sdl.PollEvent() {
-			// check event type and react
-			switch e := event.(type) {
-			case *sdl.QuitEvent:
-				ui.inputChan <- &game.Input{Typ: game.QuitGame}
-			case *sdl.WindowEvent:
-				if e.Event == sdl.WINDOWEVENT_CLOSE {
-					ui.inputChan <- &game.Input{Typ: game.CloseWindow, LevelChannel: ui.levelChan}
-				}
-			}
+// update - one frame for this window, the manager has already handed out the events
+// returns false once the game has closed the window's channel
+func (ui *ui) update() bool {
+	// keep drawing until the game closes our channel
+	if ui.closing != nil && ui.send(ui.closing) {
+		ui.closing = nil
+	}
+
+	select {
+	case newView, ok := <-ui.viewChan:
+		if !ok {
+			// game has stopped, or stopped sending to this window
+			return false
+		}
+		ui.lastView = newView
+		ui.Draw(newView)
+	default:
+	}
+
+	// TODO made a function to ask "has a key been pressed"
+	if ui.kind == PlayerCamera && (sdl.GetKeyboardFocus() == ui.window || sdl.GetMouseFocus() == ui.window) {
+		var input game.Input
+		if typ, ok := ui.movementKey(); ok {
+			input.Typ = typ
+		} else if ui.keyDownOnce(sdl.SCANCODE_PERIOD) {
+			input.Typ = game.Descend
+		} else if ui.keyDownOnce(sdl.SCANCODE_COMMA) {
+			input.Typ = game.Ascend
+		} else if ui.keyDownOnce(sdl.SCANCODE_G) {
+			input.Typ = game.PickUp
+		} else if ui.keyDownOnce(sdl.SCANCODE_D) {
+			input.Typ = game.Drop
+			input.Slot = ui.selectedSlot
+		} else if ui.keyDownOnce(sdl.SCANCODE_Q) {
+			input.Typ = game.Use
+			input.Slot = ui.selectedSlot
+		} else if ui.keyDownOnce(sdl.SCANCODE_E) {
+			input.Typ = game.Equip
+			input.Slot = ui.selectedSlot
+		} else if ui.keyDownOnce(sdl.SCANCODE_P) || ui.keyDownOnce(sdl.SCANCODE_ESCAPE) {
+			input.Typ = game.Pause
+		} else if ui.keyDownOnce(sdl.SCANCODE_R) {
+			input.Typ = game.Restart
 		}
 
-		// TODO suspect quick keypress cause channel deadlock
-		select {
-		case newLevel, ok := <-ui.levelChan:
-			if ok {
-				ui.Draw(newLevel)
-			}
-		default:
-		}
-
-		// TODO made a function to ask "has a key been pressed"
-		if sdl.GetKeyboardFocus() == ui.window || sdl.GetMouseFocus() == ui.window {
-			var input game.Input
-			if ui.keyDownOnce(sdl.SCANCODE_UP) {
-				input.Typ = game.Up
-			} else if ui.keyDownOnce(sdl.SCANCODE_DOWN) {
-				input.Typ = game.Down
-			} else if ui.keyDownOnce(sdl.SCANCODE_LEFT) {
-				input.Typ = game.Left
-			} else if ui.keyDownOnce(sdl.SCANCODE_RIGHT) {
-				input.Typ = game.Right
+		// F1 takes off the first equipment slot, F2 the second and so on
+		for slot := game.SlotHead; slot < game.NumEquipSlots; slot++ {
+			if ui.keyDownOnce(uint8(sdl.SCANCODE_F1) + uint8(slot-game.SlotHead)) {
+				input.Typ = game.Unequip
+				input.Slot = int(slot)
 			}
+		}
 
-			for i, v := range ui.keyboardState {
-				ui.prevKeyboardState[i] = v
-			}
+		if ui.keyDownOnce(sdl.SCANCODE_C) && ui.lastView != nil {
+			ui.showSheet = !ui.showSheet
+			ui.Draw(ui.lastView)
+		}
 
-			if input.Typ != game.None {
-				ui.inputChan <- &input
+		// number keys select an inventory slot, 1 is the first slot and 0 the tenth
+		for i := 0; i < game.MaxInventorySlots; i++ {
+			if ui.keyDownOnce(uint8(sdl.SCANCODE_1) + uint8(i)) {
+				ui.selectedSlot = i
 			}
 		}
-		sdl.Delay(10)
+
+		for i, v := range ui.keyboardState {
+			ui.prevKeyboardState[i] = v
+		}
+
+		// holding shift runs
+		if ui.keyboardState[sdl.SCANCODE_LSHIFT] != 0 || ui.keyboardState[sdl.SCANCODE_RSHIFT] != 0 {
+			input.Run = true
+		}
+
+		if input.Typ != game.None {
+			ui.send(&input)
+		}
 	}
+	return true
+}
+
+// destroy - frees the window once the game has stopped sending to it
+func (ui *ui) destroy() {
+	ui.renderer.Destroy()
+	ui.window.Destroy()
 }
@@ -1,11 +1,39 @@
 package ui2d
 
 import (
+	"os"
+	"strings"
 	"testing"
+
+	"github.com/veandco/go-sdl2/sdl"
 )
 
 func TestLoadTextureIndex(t *testing.T) {
+	infile, err := os.Open("assets/tiles/atlas-index.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer infile.Close()
+	index, err := parseTextureIndex(infile)
+	if err != nil {
+		t.Fatalf("shipped atlas index doesn't parse: %v", err)
+	}
+	tests := []struct {
+		tile rune
+		want sdl.Rect
+	}{
+		{'=', sdl.Rect{X: 45 * 32, Y: 30 * 32, W: 32, H: 32}},
+		{'_', sdl.Rect{X: 42 * 32, Y: 8 * 32, W: 32, H: 32}},
+	}
+	for _, test := range tests {
+		if rects := index[test.tile]; len(rects) != 1 || rects[0] != test.want {
+			t.Errorf("expected %q at %v, got %v", test.tile, test.want, rects)
+		}
+	}
 
+	if _, err := parseTextureIndex(strings.NewReader("= 45,30,1_ 42,8,1\n")); err == nil {
+		t.Error("expected two entries run together to be an error")
+	}
 }
 
 func TestImgFileToTexture(t *testing.T) {
@@ -23,3 +51,15 @@ func TestDraw(t *testing.T) {
 func TestGetInput(t *testing.T) {
 
 }
+
+func TestParseViewKind(t *testing.T) {
+	for _, kind := range []ViewKind{PlayerCamera, MapOverview, DebugCamera} {
+		parsed, err := ParseViewKind(kind.String())
+		if err != nil || parsed != kind {
+			t.Errorf("expected %v to parse back, got %v %v", kind, parsed, err)
+		}
+	}
+	if _, err := ParseViewKind("minimap"); err == nil {
+		t.Error("expected unknown view to be an error")
+	}
+}
@@ -0,0 +1,107 @@
+package ui2d
+
+import (
+	"fmt"
+
+	"github.com/rdmulford/rirpg/game"
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+// ViewKind - what a window shows of the level
+type ViewKind int
+
+const (
+	PlayerCamera ViewKind = iota // follows the player with the full hud, the only kind that takes input
+	MapOverview                  // the whole level shrunk to fit
+	DebugCamera                  // follows the player and shows the debug overlay
+)
+
+func (kind ViewKind) String() string {
+	switch kind {
+	case PlayerCamera:
+		return "player"
+	case MapOverview:
+		return "map"
+	case DebugCamera:
+		return "debug"
+	}
+	return fmt.Sprintf("ViewKind(%d)", int(kind))
+}
+
+// ParseViewKind - the kind of window named by String
+func ParseViewKind(name string) (ViewKind, error) {
+	for kind := PlayerCamera; kind <= DebugCamera; kind++ {
+		if kind.String() == name {
+			return kind, nil
+		}
+	}
+	return PlayerCamera, fmt.Errorf("unknown view %q, want player, map or debug", name)
+}
+
+var windowSizes = map[ViewKind]struct{ width, height int }{
+	PlayerCamera: {1920, 1080},
+	MapOverview:  {800, 800},
+	DebugCamera:  {1280, 720},
+}
+
+// Manager - owns every window and the one sdl event pump they share
+// sdl has to be driven from a single thread, so Run belongs on the main goroutine
+type Manager struct {
+	inputChan chan<- *game.Input
+	windows   map[uint32]*ui
+	order     []uint32 // windows in the order they were opened, so they draw in a steady order
+	quitting  bool     // sdl asked to quit and the game hasn't been told yet
+}
+
+func NewManager(inputChan chan<- *game.Input) *Manager {
+	m := &Manager{}
+	m.inputChan = inputChan
+	m.windows = make(map[uint32]*ui)
+	return m
+}
+
+// Open - adds a window showing views from viewChan, it closes itself once the channel does
+func (m *Manager) Open(kind ViewKind, viewChan <-chan *game.View) {
+	ui := newWindow(kind, m.inputChan, viewChan)
+	m.windows[ui.id] = ui
+	m.order = append(m.order, ui.id)
+}
+
+// Run - pumps events to the windows and draws them until every window is gone
+func (m *Manager) Run() {
+	for len(m.windows) > 0 {
+		for event := sdl.PollEvent(); event != nil; event = sdl.PollEvent() {
+			// check event type and react
+			switch e := event.(type) {
+			case *sdl.QuitEvent:
+				m.quitting = true
+			case *sdl.WindowEvent:
+				ui, exists := m.windows[e.WindowID]
+				if exists && e.Event == sdl.WINDOWEVENT_CLOSE {
+					ui.closing = &game.Input{Typ: game.CloseWindow, Views: ui.viewChan}
+				}
+			}
+		}
+		// quitting stops the game, which closes every window's channel
+		if m.quitting {
+			select {
+			case m.inputChan <- &game.Input{Typ: game.QuitGame}:
+				m.quitting = false
+			default:
+			}
+		}
+
+		open := m.order[:0]
+		for _, id := range m.order {
+			ui := m.windows[id]
+			if ui.update() {
+				open = append(open, id)
+				continue
+			}
+			ui.destroy()
+			delete(m.windows, id)
+		}
+		m.order = open
+		sdl.Delay(10)
+	}
+}
@@ -11,12 +11,15 @@ type Pos struct {
 	X, Y int
 }
 
-func GenerateNewLevel(xSize, ySize int, seed int64) [][]rune {
+// GenerateNewLevel - monsters holds the glyph of each monster to place
+func GenerateNewLevel(xSize, ySize int, seed int64, monsters []rune) [][]rune {
 	genMap := make([][]rune, ySize)
 	for i := range genMap {
 		genMap[i] = make([]rune, xSize)
 	}
 	openTiles := make([]Pos, 0)
+	// everything placed comes from the same seed as the terrain, so a seed always gives the same level
+	r := rand.New(rand.NewSource(seed))
 
 	// define world tiles base don perlin noise
 	p := NewPerlin(2, 2, 3, seed)
@@ -43,30 +46,32 @@ func GenerateNewLevel(xSize, ySize int, seed int64) [][]rune {
 
 	// place trees
 	for i := 0; i < 200; i++ {
-		placeTile(openTiles, genMap, rune('^'))
+		openTiles = placeTile(r, openTiles, genMap, rune('^'))
 	}
 
 	// place monsters
-	for i := 0; i < 5; i++ {
-		placeTile(openTiles, genMap, rune('R'))
-	}
-	for i := 0; i < 5; i++ {
-		placeTile(openTiles, genMap, rune('S'))
+	for _, monster := range monsters {
+		openTiles = placeTile(r, openTiles, genMap, monster)
 	}
 
+	// place stairs down
+	openTiles = placeTile(r, openTiles, genMap, rune('>'))
+
 	// place player
-	placeTile(openTiles, genMap, rune('@'))
+	openTiles = placeTile(r, openTiles, genMap, rune('@'))
 
 	return genMap
 }
 
 // utilize perlin noise to generate new level file at game/maps/level1.map
-func GenerateNewLevelToFile(xSize, ySize int, seed int64) {
+func GenerateNewLevelToFile(xSize, ySize int, seed int64, monsters []rune) {
 	genMap := make([][]rune, ySize)
 	for i := range genMap {
 		genMap[i] = make([]rune, xSize)
 	}
 	openTiles := make([]Pos, 0)
+	// everything placed comes from the same seed as the terrain, so a seed always gives the same level
+	r := rand.New(rand.NewSource(seed))
 
 	// define world tiles base don perlin noise
 	p := NewPerlin(2, 2, 3, seed)
@@ -93,19 +98,19 @@ func GenerateNewLevelToFile(xSize, ySize int, seed int64) {
 
 	// place trees
 	for i := 0; i < 200; i++ {
-		placeTile(openTiles, genMap, rune('^'))
+		openTiles = placeTile(r, openTiles, genMap, rune('^'))
 	}
 
 	// place monsters
-	for i := 0; i < 5; i++ {
-		placeTile(openTiles, genMap, rune('R'))
-	}
-	for i := 0; i < 5; i++ {
-		placeTile(openTiles, genMap, rune('S'))
+	for _, monster := range monsters {
+		openTiles = placeTile(r, openTiles, genMap, monster)
 	}
 
+	// place stairs down
+	openTiles = placeTile(r, openTiles, genMap, rune('>'))
+
 	// place player
-	placeTile(openTiles, genMap, rune('@'))
+	openTiles = placeTile(r, openTiles, genMap, rune('@'))
 
 	// delete old level
 	err := os.Remove("game/maps/level1.map")
@@ -135,12 +140,12 @@ func GenerateNewLevelToFile(xSize, ySize int, seed int64) {
 	w.Flush()
 }
 
-// place a new tile on an open tile
-func placeTile(openTiles []Pos, genMap [][]rune, tile rune) {
-	index := rand.Intn(len(openTiles))
+// place a new tile on an open tile, returns the tiles still open
+func placeTile(r *rand.Rand, openTiles []Pos, genMap [][]rune, tile rune) []Pos {
+	index := r.Intn(len(openTiles))
 	mPos := openTiles[index]
-	remove(openTiles, index)
 	genMap[mPos.X][mPos.Y] = tile
+	return remove(openTiles, index)
 }
 
 func remove(s []Pos, i int) []Pos {